@@ -0,0 +1,156 @@
+package adjust
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/nzai/stockrecorder/market"
+)
+
+const (
+	// ModeNone 不复权
+	ModeNone = "none"
+	// ModeSplit 只按拆股复权
+	ModeSplit = "split"
+	// ModeDividend 按拆股及分红复权
+	ModeDividend = "dividend"
+)
+
+// ValidateMode 校验复权方式，为空时使用默认的拆股复权
+func ValidateMode(mode string) error {
+
+	switch mode {
+	case "", ModeNone, ModeSplit, ModeDividend:
+		return nil
+	default:
+		return fmt.Errorf("未知的复权方式:%s", mode)
+	}
+}
+
+// Raw 一天的原始报价
+type Raw struct {
+	Date  time.Time                // 日期(市场所在时区的0点)
+	Quote market.CompanyDailyQuote // 原始报价
+}
+
+// Series 复权后的报价序列，价格单位为元(原始报价为元×100的整数)
+type Series struct {
+	Timestamp []uint32  `json:"timestamp"`
+	Open      []float64 `json:"open"`
+	Close     []float64 `json:"close"`
+	Max       []float64 `json:"max"`
+	Min       []float64 `json:"min"`
+	Volume    []float64 `json:"volume"`
+}
+
+// Day 一天的复权报价
+type Day struct {
+	Date         time.Time `json:"date"`
+	Code         string    `json:"code"`
+	PriceFactor  float64   `json:"priceFactor"`  // 原始价格乘以此因子
+	VolumeFactor float64   `json:"volumeFactor"` // 原始成交量乘以此因子
+	Pre          Series    `json:"pre"`
+	Regular      Series    `json:"regular"`
+	Post         Series    `json:"post"`
+}
+
+// Adjust 前复权: 以最后一天为基准，按之后各天发生的拆股(dividend时还有分红)调整之前的价格和成交量，原始报价不变。
+// days按日期升序，events为同一时间段内该公司的事件，返回复权报价及无法使用的事件(分红前一天没有收盘价等)
+func Adjust(mode string, days []Raw, events market.EventList) ([]Day, market.EventList) {
+
+	// 从后往前依次应用事件
+	descending := append(market.EventList(nil), events...)
+	sort.SliceStable(descending, func(i, j int) bool {
+		return descending[i].Timestamp > descending[j].Timestamp
+	})
+
+	var skipped market.EventList
+	priceFactor, volumeFactor := 1.0, 1.0
+	adjusted := make([]Day, len(days))
+	next := 0
+	for index := len(days) - 1; index >= 0; index-- {
+
+		raw := days[index]
+
+		// 当天结束之后的事件影响当天及之前的报价
+		dayEnd := raw.Date.AddDate(0, 0, 1).Unix()
+		for ; next < len(descending) && descending[next].Timestamp >= dayEnd; next++ {
+
+			event := descending[next]
+			switch {
+			case event.Type == market.EventSplit && mode != ModeNone:
+				if event.Ratio() == 1 {
+					skipped = append(skipped, event)
+					continue
+				}
+				priceFactor /= event.Ratio()
+				volumeFactor *= event.Ratio()
+
+			case event.Type == market.EventDividend && mode == ModeDividend:
+				// 按除息日前一天的收盘价计算: 1 - 每股分红 / 收盘价
+				_close, found := lastClose(raw.Quote)
+				if !found || event.Amount <= 0 || event.Amount >= _close {
+					skipped = append(skipped, event)
+					continue
+				}
+				priceFactor *= 1 - event.Amount/_close
+			}
+		}
+
+		adjusted[index] = Day{
+			Date:         raw.Date,
+			Code:         raw.Quote.Code,
+			PriceFactor:  priceFactor,
+			VolumeFactor: volumeFactor,
+			Pre:          scale(raw.Quote.Pre, priceFactor, volumeFactor),
+			Regular:      scale(raw.Quote.Regular, priceFactor, volumeFactor),
+			Post:         scale(raw.Quote.Post, priceFactor, volumeFactor),
+		}
+	}
+
+	return adjusted, skipped
+}
+
+// lastClose 当天盘中最后一笔报价的收盘价(元)
+func lastClose(quote market.CompanyDailyQuote) (float64, bool) {
+
+	if quote.Regular.Count == 0 {
+		return 0, false
+	}
+
+	_close := quote.Regular.Close[quote.Regular.Count-1]
+	if _close == 0 {
+		return 0, false
+	}
+
+	return float64(_close) / 100, true
+}
+
+// scale 按因子调整报价序列
+func scale(series market.QuoteSeries, priceFactor, volumeFactor float64) Series {
+
+	result := Series{
+		Timestamp: make([]uint32, 0, series.Count),
+		Open:      make([]float64, 0, series.Count),
+		Close:     make([]float64, 0, series.Count),
+		Max:       make([]float64, 0, series.Count),
+		Min:       make([]float64, 0, series.Count),
+		Volume:    make([]float64, 0, series.Count),
+	}
+
+	price := func(value uint32) float64 {
+		return float64(value) / 100 * priceFactor
+	}
+
+	for index := 0; index < int(series.Count); index++ {
+		result.Timestamp = append(result.Timestamp, series.Timestamp[index])
+		result.Open = append(result.Open, price(series.Open[index]))
+		result.Close = append(result.Close, price(series.Close[index]))
+		result.Max = append(result.Max, price(series.Max[index]))
+		result.Min = append(result.Min, price(series.Min[index]))
+		result.Volume = append(result.Volume, float64(series.Volume[index])*volumeFactor)
+	}
+
+	return result
+}
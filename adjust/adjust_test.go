@@ -0,0 +1,140 @@
+package adjust
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/nzai/stockrecorder/market"
+)
+
+// testDays 纽交所连续3个交易日的原始报价，每天一笔盘中报价，01-03起2:1拆股
+func testDays(t *testing.T) ([]Raw, *time.Location) {
+
+	location, err := time.LoadLocation(market.America{}.Timezone())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var days []Raw
+	for index, price := range []uint32{10000, 5000, 5100} {
+
+		date := time.Date(2018, 1, 2+index, 0, 0, 0, 0, location)
+		open := uint32(date.Add(time.Hour*9 + time.Minute*30).Unix())
+
+		days = append(days, Raw{
+			Date: date,
+			Quote: market.CompanyDailyQuote{
+				Company: market.Company{Code: "AAPL"},
+				Regular: market.QuoteSeries{
+					Count:     1,
+					Timestamp: []uint32{open},
+					Open:      []uint32{price},
+					Close:     []uint32{price},
+					Max:       []uint32{price},
+					Min:       []uint32{price},
+					Volume:    []uint32{1000},
+				},
+			},
+		})
+	}
+
+	return days, location
+}
+
+// almostEqual 浮点数是否相等
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestAdjust(t *testing.T) {
+
+	days, location := testDays(t)
+
+	events := market.EventList{
+		// 恰好在01-02结束时(01-03的0点)的拆股只影响01-02
+		{Code: "AAPL", Type: market.EventSplit, Timestamp: time.Date(2018, 1, 3, 0, 0, 0, 0, location).Unix(), Numerator: 2, Denominator: 1},
+		// 01-04开盘时除息，按01-03的收盘价50元计算，影响01-03及之前
+		{Code: "AAPL", Type: market.EventDividend, Timestamp: time.Date(2018, 1, 4, 9, 30, 0, 0, location).Unix(), Amount: 0.5},
+	}
+
+	tests := []struct {
+		mode          string
+		priceFactors  []float64
+		volumeFactors []float64
+	}{
+		{ModeNone, []float64{1, 1, 1}, []float64{1, 1, 1}},
+		{ModeSplit, []float64{0.5, 1, 1}, []float64{2, 1, 1}},
+		{ModeDividend, []float64{0.5 * 0.99, 0.99, 1}, []float64{2, 1, 1}},
+	}
+
+	for _, test := range tests {
+
+		adjusted, skipped := Adjust(test.mode, days, events)
+		if len(skipped) != 0 {
+			t.Errorf("[%s] 忽略了事件: %v", test.mode, skipped)
+		}
+
+		if len(adjusted) != len(days) {
+			t.Fatalf("[%s] 复权后有%d天，应为%d天", test.mode, len(adjusted), len(days))
+		}
+
+		for index, day := range adjusted {
+
+			if !almostEqual(day.PriceFactor, test.priceFactors[index]) || !almostEqual(day.VolumeFactor, test.volumeFactors[index]) {
+				t.Errorf("[%s] %s的价格因子%v、成交量因子%v，应为%v、%v", test.mode, day.Date.Format("20060102"),
+					day.PriceFactor, day.VolumeFactor, test.priceFactors[index], test.volumeFactors[index])
+			}
+
+			// 价格单位为元
+			price := float64(days[index].Quote.Regular.Close[0]) / 100 * test.priceFactors[index]
+			if !almostEqual(day.Regular.Close[0], price) || !almostEqual(day.Regular.Volume[0], 1000*test.volumeFactors[index]) {
+				t.Errorf("[%s] %s复权后的收盘价%v、成交量%v，应为%v、%v", test.mode, day.Date.Format("20060102"),
+					day.Regular.Close[0], day.Regular.Volume[0], price, 1000*test.volumeFactors[index])
+			}
+		}
+	}
+
+	// 原始报价不变
+	if days[0].Quote.Regular.Close[0] != 10000 {
+		t.Error("复权改动了原始报价")
+	}
+}
+
+func TestAdjustSkipped(t *testing.T) {
+
+	days, location := testDays(t)
+
+	events := market.EventList{
+		// 第一天当中的事件不影响任何一天
+		{Code: "AAPL", Type: market.EventSplit, Timestamp: time.Date(2018, 1, 2, 10, 0, 0, 0, location).Unix(), Numerator: 3, Denominator: 1},
+		// 比例错误的拆股
+		{Code: "AAPL", Type: market.EventSplit, Timestamp: time.Date(2018, 1, 3, 9, 30, 0, 0, location).Unix(), Numerator: 1, Denominator: 1},
+		// 分红不低于前一天的收盘价
+		{Code: "AAPL", Type: market.EventDividend, Timestamp: time.Date(2018, 1, 4, 9, 30, 0, 0, location).Unix(), Amount: 50},
+	}
+
+	adjusted, skipped := Adjust(ModeDividend, days, events)
+	if len(skipped) != 2 || skipped[0].Type != market.EventDividend || skipped[1].Type != market.EventSplit {
+		t.Errorf("忽略的事件为%v，应为分红及比例错误的拆股", skipped)
+	}
+
+	for _, day := range adjusted {
+		if day.PriceFactor != 1 || day.VolumeFactor != 1 {
+			t.Errorf("%s的价格因子%v、成交量因子%v，应为1", day.Date.Format("20060102"), day.PriceFactor, day.VolumeFactor)
+		}
+	}
+}
+
+func TestValidateMode(t *testing.T) {
+
+	for _, mode := range []string{"", ModeNone, ModeSplit, ModeDividend} {
+		if err := ValidateMode(mode); err != nil {
+			t.Errorf("%s: %v", mode, err)
+		}
+	}
+
+	if err := ValidateMode("forward"); err == nil {
+		t.Error("forward应为未知的复权方式")
+	}
+}
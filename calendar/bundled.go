@@ -0,0 +1,11 @@
+package calendar
+
+import (
+	// 内置交易日历数据
+	_ "embed"
+)
+
+// bundledData 内置的交易日历数据
+//
+//go:embed calendar.yaml
+var bundledData string
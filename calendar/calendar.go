@@ -0,0 +1,548 @@
+package calendar
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nzai/go-utility/io"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	datePattern  = "2006-01-02"
+	clockPattern = "15:04"
+)
+
+// observed 节假日遇到周末时的补假方式
+const (
+	observedNone    = ""        // 不补假
+	observedNearest = "nearest" // 周六提前到周五，周日顺延到周一(纽交所)
+	observedSunday  = "sunday"  // 仅周日顺延到周一(港交所)
+	observedForward = "forward" // 顺延到下一个非节假日的工作日(伦交所)
+)
+
+// 交易时段类型
+const (
+	// SessionPre 盘前
+	SessionPre = "pre"
+	// SessionRegular 盘中
+	SessionRegular = "regular"
+	// SessionPost 盘后
+	SessionPost = "post"
+)
+
+// Session 一天中的一个交易时段
+type Session struct {
+	Name  string    // 名称，如 morning、afternoon
+	Type  string    // 类型: pre、regular、post
+	Start time.Time // 开始时间(含)
+	End   time.Time // 结束时间(不含)
+}
+
+// Calendar 交易日历
+type Calendar struct {
+	name     string
+	open     clock                 // 开市时间
+	close    clock                 // 收市时间
+	sessions []session             // 交易时段，没有配置时只有open至close一个盘中时段
+	weekends map[time.Weekday]bool // 休市的星期
+	holidays []holiday             // 休市的节假日
+	halfDays []holiday             // 半日市
+	from, to int                   // 不规则节假日(农历节日等)的数据覆盖年份，0表示不限
+
+	mutex sync.Mutex
+	years map[int]*yearDays // 按年缓存计算结果
+}
+
+// yearDays 某年的节假日
+type yearDays struct {
+	holidays map[string]string // 日期 -> 节假日名称
+	halfDays map[string]clock  // 日期 -> 提前收市时间
+}
+
+// holiday 节假日
+type holiday struct {
+	name     string
+	rule     rule      // 按规则每年计算
+	from, to time.Time // 或是指定的日期区间(含)
+	observed string    // 补假方式
+	since    int       // 起始年份(含)
+	until    int       // 截止年份(含)
+	close    clock     // 半日市的收市时间
+}
+
+// session 交易时段
+type session struct {
+	name       string
+	_type      string
+	start, end clock
+}
+
+// clock 一天中的时间(分钟)
+type clock int
+
+// parseClock 解析时间 15:04
+func parseClock(text string) (clock, error) {
+
+	t, err := time.Parse(clockPattern, text)
+	if err != nil {
+		return 0, fmt.Errorf("错误的时间格式:%s", text)
+	}
+
+	return clock(t.Hour()*60 + t.Minute()), nil
+}
+
+// On 当天的具体时间
+func (c clock) On(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), int(c)/60, int(c)%60, 0, 0, date.Location())
+}
+
+// String 显示
+func (c clock) String() string {
+	return fmt.Sprintf("%02d:%02d", int(c)/60, int(c)%60)
+}
+
+// Name 名称
+func (c *Calendar) Name() string {
+	return c.name
+}
+
+// IsTradingDay 是否为交易日
+func (c *Calendar) IsTradingDay(date time.Time) bool {
+
+	if c.weekends[date.Weekday()] {
+		return false
+	}
+
+	_, found := c.Holiday(date)
+	return !found
+}
+
+// Holiday 是否为节假日，返回节假日名称
+func (c *Calendar) Holiday(date time.Time) (string, bool) {
+	name, found := c.year(date.Year()).holidays[date.Format(datePattern)]
+	return name, found
+}
+
+// HalfDay 是否为半日市，返回提前收市的时间
+func (c *Calendar) HalfDay(date time.Time) (time.Time, bool) {
+
+	if !c.IsTradingDay(date) {
+		return time.Time{}, false
+	}
+
+	close, found := c.year(date.Year()).halfDays[date.Format(datePattern)]
+	if !found {
+		return time.Time{}, false
+	}
+
+	return close.On(date), true
+}
+
+// Open 开市时间
+func (c *Calendar) Open(date time.Time) time.Time {
+	return c.open.On(date)
+}
+
+// Close 收市时间(半日市为提前收市的时间)
+func (c *Calendar) Close(date time.Time) time.Time {
+
+	if close, found := c.HalfDay(date); found {
+		return close
+	}
+
+	return c.close.On(date)
+}
+
+// Sessions 某天的交易时段，按时间排序，非交易日返回nil。
+// 半日市时盘中时段截止到提前收市的时间，之后的盘中时段取消，原来在收市时开始的盘后时段改为在提前收市时开始
+func (c *Calendar) Sessions(date time.Time) []Session {
+
+	if !c.IsTradingDay(date) {
+		return nil
+	}
+
+	sessions := c.sessions
+	if len(sessions) == 0 {
+		sessions = []session{{name: SessionRegular, _type: SessionRegular, start: c.open, end: c.close}}
+	}
+
+	early, half := c.year(date.Year()).halfDays[date.Format(datePattern)]
+
+	result := make([]Session, 0, len(sessions))
+	for _, s := range sessions {
+
+		start, end := s.start, s.end
+		if half {
+			switch s._type {
+			case SessionRegular:
+				if start >= early {
+					continue
+				}
+				if end > early {
+					end = early
+				}
+			case SessionPost:
+				if start == c.close {
+					start = early
+				}
+			}
+		}
+
+		result = append(result, Session{Name: s.name, Type: s._type, Start: start.On(date), End: end.On(date)})
+	}
+
+	return result
+}
+
+// NextTradingDay 下一个交易日(不含当天)
+func (c *Calendar) NextTradingDay(date time.Time) time.Time {
+
+	date = date.AddDate(0, 0, 1)
+	for !c.IsTradingDay(date) {
+		date = date.AddDate(0, 0, 1)
+	}
+
+	return date
+}
+
+// PrevTradingDay 上一个交易日(不含当天)
+func (c *Calendar) PrevTradingDay(date time.Time) time.Time {
+
+	date = date.AddDate(0, 0, -1)
+	for !c.IsTradingDay(date) {
+		date = date.AddDate(0, 0, -1)
+	}
+
+	return date
+}
+
+// Covers 日历数据是否覆盖了某年的全部节假日
+func (c *Calendar) Covers(year int) bool {
+	return (c.from == 0 || year >= c.from) && (c.to == 0 || year <= c.to)
+}
+
+// year 计算某年的节假日
+func (c *Calendar) year(year int) *yearDays {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if days, found := c.years[year]; found {
+		return days
+	}
+
+	days := &yearDays{
+		holidays: make(map[string]string),
+		halfDays: make(map[string]clock),
+	}
+
+	// 补假可能跨年(如元旦为周六时提前到上一年的12月31日)，所以前后各多算一年
+	for _, h := range c.holidays {
+		for y := year - 1; y <= year+1; y++ {
+			for _, date := range h.dates(y) {
+				date = c.observe(date, h.observed, days)
+				if date.Year() != year {
+					continue
+				}
+
+				if _, found := days.holidays[date.Format(datePattern)]; !found {
+					days.holidays[date.Format(datePattern)] = h.name
+				}
+			}
+		}
+	}
+
+	for _, h := range c.halfDays {
+		for _, date := range h.dates(year) {
+			days.halfDays[date.Format(datePattern)] = h.close
+		}
+	}
+
+	c.years[year] = days
+
+	return days
+}
+
+// observe 节假日遇到周末时补假
+func (c *Calendar) observe(date time.Time, observed string, days *yearDays) time.Time {
+
+	switch observed {
+	case observedNearest:
+		switch date.Weekday() {
+		case time.Saturday:
+			return date.AddDate(0, 0, -1)
+		case time.Sunday:
+			return date.AddDate(0, 0, 1)
+		}
+	case observedSunday:
+		if date.Weekday() == time.Sunday {
+			return date.AddDate(0, 0, 1)
+		}
+	case observedForward:
+		for c.weekends[date.Weekday()] || days.holidays[date.Format(datePattern)] != "" {
+			date = date.AddDate(0, 0, 1)
+		}
+	}
+
+	return date
+}
+
+// dates 节假日在某年的日期
+func (h holiday) dates(year int) []time.Time {
+
+	if h.since != 0 && year < h.since || h.until != 0 && year > h.until {
+		return nil
+	}
+
+	if h.rule != nil {
+		return []time.Time{h.rule.Date(year)}
+	}
+
+	var dates []time.Time
+	for date := h.from; !date.After(h.to); date = date.AddDate(0, 0, 1) {
+		if date.Year() == year {
+			dates = append(dates, date)
+		}
+	}
+
+	return dates
+}
+
+var (
+	// calendars 已加载的交易日历
+	calendars = map[string]*Calendar{}
+	// calendarsMutex 交易日历锁
+	calendarsMutex sync.RWMutex
+)
+
+func init() {
+	err := Load([]byte(bundledData))
+	if err != nil {
+		panic(fmt.Errorf("加载内置交易日历时发生错误: %v", err))
+	}
+}
+
+// Get 获取市场的交易日历，未配置的市场只按周六、周日休市
+func Get(name string) *Calendar {
+
+	calendarsMutex.RLock()
+	c, found := calendars[strings.ToLower(name)]
+	calendarsMutex.RUnlock()
+
+	if found {
+		return c
+	}
+
+	return &Calendar{
+		name:     name,
+		open:     0,
+		close:    24 * 60,
+		weekends: map[time.Weekday]bool{time.Saturday: true, time.Sunday: true},
+		years:    make(map[int]*yearDays),
+	}
+}
+
+// LoadFile 从文件加载交易日历，覆盖同名市场的内置日历
+func LoadFile(path string) error {
+
+	buffer, err := io.ReadAllBytes(path)
+	if err != nil {
+		return err
+	}
+
+	return Load(buffer)
+}
+
+// Load 加载交易日历，覆盖同名市场的已有日历
+func Load(buffer []byte) error {
+
+	var data struct {
+		Markets map[string]marketSpec `yaml:"markets"`
+	}
+
+	err := yaml.Unmarshal(buffer, &data)
+	if err != nil {
+		return err
+	}
+
+	loaded := make(map[string]*Calendar, len(data.Markets))
+	for name, spec := range data.Markets {
+		c, err := spec.build(name)
+		if err != nil {
+			return fmt.Errorf("[%s] 交易日历错误: %v", name, err)
+		}
+
+		loaded[strings.ToLower(name)] = c
+	}
+
+	calendarsMutex.Lock()
+	for name, c := range loaded {
+		calendars[name] = c
+	}
+	calendarsMutex.Unlock()
+
+	return nil
+}
+
+// marketSpec 数据文件中的市场交易日历
+type marketSpec struct {
+	Open     string        `yaml:"open"`
+	Close    string        `yaml:"close"`
+	Weekends []string      `yaml:"weekends"`
+	Coverage []int         `yaml:"coverage"`
+	Holidays []holidaySpec `yaml:"holidays"`
+	HalfDays []holidaySpec `yaml:"halfdays"`
+	Sessions []sessionSpec `yaml:"sessions"`
+}
+
+// sessionSpec 数据文件中的交易时段
+type sessionSpec struct {
+	Name  string `yaml:"name"`
+	Type  string `yaml:"type"` // pre、regular、post，默认regular
+	Open  string `yaml:"open"`
+	Close string `yaml:"close"`
+}
+
+// holidaySpec 数据文件中的节假日
+type holidaySpec struct {
+	Name     string `yaml:"name"`
+	Rule     string `yaml:"rule"`     // 每年的规则: 01-01、3 Monday January、last Monday May、easter-2
+	Date     string `yaml:"date"`     // 或指定日期: 2018-02-15
+	To       string `yaml:"to"`       // 指定日期区间的结束日期(含)
+	Observed string `yaml:"observed"` // 补假方式: nearest、sunday、forward
+	Since    int    `yaml:"since"`    // 起始年份(含)
+	Until    int    `yaml:"until"`    // 截止年份(含)
+	Close    string `yaml:"close"`    // 半日市的收市时间
+}
+
+// build 构造交易日历
+func (s marketSpec) build(name string) (*Calendar, error) {
+
+	c := &Calendar{
+		name:     name,
+		weekends: make(map[time.Weekday]bool),
+		years:    make(map[int]*yearDays),
+	}
+
+	var err error
+	c.open, err = parseClock(s.Open)
+	if err != nil {
+		return nil, err
+	}
+
+	c.close, err = parseClock(s.Close)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, text := range s.Weekends {
+		weekday, found := weekdays[strings.ToLower(text)]
+		if !found {
+			return nil, fmt.Errorf("错误的星期:%s", text)
+		}
+		c.weekends[weekday] = true
+	}
+
+	if len(s.Coverage) == 2 {
+		c.from, c.to = s.Coverage[0], s.Coverage[1]
+	}
+
+	for _, spec := range s.Sessions {
+
+		ss := session{name: spec.Name, _type: spec.Type}
+		switch ss._type {
+		case "":
+			ss._type = SessionRegular
+		case SessionPre, SessionRegular, SessionPost:
+		default:
+			return nil, fmt.Errorf("交易时段[%s]错误的类型:%s", spec.Name, spec.Type)
+		}
+
+		ss.start, err = parseClock(spec.Open)
+		if err != nil {
+			return nil, err
+		}
+
+		ss.end, err = parseClock(spec.Close)
+		if err != nil {
+			return nil, err
+		}
+
+		if ss.end <= ss.start {
+			return nil, fmt.Errorf("交易时段[%s]的结束时间%s不晚于开始时间%s", spec.Name, spec.Close, spec.Open)
+		}
+
+		if count := len(c.sessions); count > 0 && ss.start < c.sessions[count-1].end {
+			return nil, fmt.Errorf("交易时段[%s]与前一个时段重叠，时段需要按时间顺序排列", spec.Name)
+		}
+
+		c.sessions = append(c.sessions, ss)
+	}
+
+	for _, spec := range s.Holidays {
+		h, err := spec.build()
+		if err != nil {
+			return nil, err
+		}
+		c.holidays = append(c.holidays, h)
+	}
+
+	for _, spec := range s.HalfDays {
+		h, err := spec.build()
+		if err != nil {
+			return nil, err
+		}
+
+		if spec.Close == "" {
+			return nil, fmt.Errorf("半日市[%s]未指定收市时间", spec.Name)
+		}
+
+		h.close, err = parseClock(spec.Close)
+		if err != nil {
+			return nil, err
+		}
+		c.halfDays = append(c.halfDays, h)
+	}
+
+	return c, nil
+}
+
+// build 构造节假日
+func (s holidaySpec) build() (holiday, error) {
+
+	h := holiday{name: s.Name, observed: s.Observed, since: s.Since, until: s.Until}
+
+	switch s.Observed {
+	case observedNone, observedNearest, observedSunday, observedForward:
+	default:
+		return h, fmt.Errorf("节假日[%s]错误的补假方式:%s", s.Name, s.Observed)
+	}
+
+	if s.Rule != "" {
+		r, err := parseRule(s.Rule)
+		if err != nil {
+			return h, err
+		}
+		h.rule = r
+
+		return h, nil
+	}
+
+	from, err := time.Parse(datePattern, s.Date)
+	if err != nil {
+		return h, fmt.Errorf("节假日[%s]错误的日期:%s", s.Name, s.Date)
+	}
+	h.from, h.to = from, from
+
+	if s.To != "" {
+		h.to, err = time.Parse(datePattern, s.To)
+		if err != nil {
+			return h, fmt.Errorf("节假日[%s]错误的日期:%s", s.Name, s.To)
+		}
+	}
+
+	return h, nil
+}
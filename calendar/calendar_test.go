@@ -0,0 +1,161 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+// testDay 解析日期
+func testDay(t *testing.T, text string) time.Time {
+
+	date, err := time.Parse(datePattern, text)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return date
+}
+
+func TestObserved(t *testing.T) {
+
+	err := Load([]byte(`
+markets:
+  nearesttest:
+    open: "09:30"
+    close: "16:00"
+    weekends: [Saturday, Sunday]
+    holidays:
+      - {name: "New Year", rule: "01-01", observed: nearest}
+      - {name: "Independence Day", rule: "07-04", observed: nearest}
+  sundaytest:
+    open: "09:30"
+    close: "16:00"
+    weekends: [Saturday, Sunday]
+    holidays:
+      - {name: "Christmas", rule: "12-25", observed: sunday}
+  forwardtest:
+    open: "09:30"
+    close: "16:00"
+    weekends: [Saturday, Sunday]
+    holidays:
+      - {name: "Christmas", rule: "12-25", observed: forward}
+      - {name: "Boxing Day", rule: "12-26", observed: forward}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		market  string
+		date    string
+		trading bool
+	}{
+		// 周六提前到周五，周日顺延到周一
+		{"nearesttest", "2020-07-03", false},
+		{"nearesttest", "2020-07-06", true},
+		{"nearesttest", "2021-07-05", false},
+		{"nearesttest", "2021-07-02", true},
+		// 补假跨年: 2022-01-01为周六
+		{"nearesttest", "2021-12-31", false},
+		{"nearesttest", "2022-01-03", true},
+		// 只有周日顺延
+		{"sundaytest", "2021-12-24", true},
+		{"sundaytest", "2021-12-27", true},
+		{"sundaytest", "2022-12-26", false},
+		{"sundaytest", "2022-12-27", true},
+		// 顺延到下一个非节假日的工作日
+		{"forwardtest", "2021-12-27", false},
+		{"forwardtest", "2021-12-28", false},
+		{"forwardtest", "2021-12-29", true},
+		{"forwardtest", "2020-12-25", false},
+		{"forwardtest", "2020-12-28", false},
+		{"forwardtest", "2020-12-29", true},
+	}
+
+	for _, test := range tests {
+		if trading := Get(test.market).IsTradingDay(testDay(t, test.date)); trading != test.trading {
+			t.Errorf("[%s] %s是否为交易日: %v，应为%v", test.market, test.date, trading, test.trading)
+		}
+	}
+}
+
+func TestBundledCalendars(t *testing.T) {
+
+	tests := []struct {
+		market  string
+		date    string
+		trading bool
+		holiday string
+	}{
+		// 港交所圣诞节只在周日时顺延，圣诞节翌日顺延到下一个工作日
+		{"hongkong", "2021-12-24", true, ""},
+		{"hongkong", "2021-12-27", false, "聖誕節翌日"},
+		{"hongkong", "2021-12-28", true, ""},
+		{"hongkong", "2022-12-26", false, "聖誕節"},
+		{"hongkong", "2022-12-27", false, "聖誕節翌日"},
+		{"hongkong", "2022-12-28", true, ""},
+		{"hongkong", "2024-12-25", false, "聖誕節"},
+		{"hongkong", "2024-12-26", false, "聖誕節翌日"},
+		{"hongkong", "2024-12-27", true, ""},
+		{"hongkong", "2019-04-19", false, "耶穌受難節"},
+		{"hongkong", "2019-04-22", false, "復活節星期一"},
+		// 伦交所圣诞节及节礼日都顺延
+		{"england", "2021-12-27", false, "Christmas Day"},
+		{"england", "2021-12-28", false, "Boxing Day"},
+		// 纽交所
+		{"america", "2018-11-22", false, "Thanksgiving Day"},
+		{"america", "2021-12-24", false, "Christmas Day"},
+		// 农历节日
+		{"china", "2024-02-09", false, "春节"},
+		{"china", "2024-02-19", true, ""},
+	}
+
+	for _, test := range tests {
+
+		c := Get(test.market)
+		date := testDay(t, test.date)
+
+		if trading := c.IsTradingDay(date); trading != test.trading {
+			t.Errorf("[%s] %s是否为交易日: %v，应为%v", test.market, test.date, trading, test.trading)
+		}
+
+		if name, _ := c.Holiday(date); name != test.holiday {
+			t.Errorf("[%s] %s的节假日为%s，应为%s", test.market, test.date, name, test.holiday)
+		}
+	}
+}
+
+func TestHalfDays(t *testing.T) {
+
+	c := Get("hongkong")
+
+	close, found := c.HalfDay(testDay(t, "2021-12-24"))
+	if !found || close.Format(clockPattern) != "12:00" {
+		t.Errorf("2021-12-24应为半日市: %v %s", found, close.Format(clockPattern))
+	}
+
+	if _, found := c.HalfDay(testDay(t, "2021-12-23")); found {
+		t.Error("2021-12-23不是半日市")
+	}
+}
+
+func TestCovers(t *testing.T) {
+
+	for _, test := range []struct {
+		market string
+		year   int
+		covers bool
+	}{
+		{"china", 2016, false},
+		{"china", 2017, true},
+		{"china", 2026, true},
+		{"china", 2027, false},
+		{"hongkong", 2027, false},
+		// 没有不规则节假日的市场不限
+		{"america", 2040, true},
+	} {
+		if covers := Get(test.market).Covers(test.year); covers != test.covers {
+			t.Errorf("[%s] 是否覆盖%d年: %v，应为%v", test.market, test.year, covers, test.covers)
+		}
+	}
+}
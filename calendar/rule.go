@@ -0,0 +1,189 @@
+package calendar
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// weekdays 星期名称
+	weekdays = map[string]time.Weekday{
+		"sunday":    time.Sunday,
+		"monday":    time.Monday,
+		"tuesday":   time.Tuesday,
+		"wednesday": time.Wednesday,
+		"thursday":  time.Thursday,
+		"friday":    time.Friday,
+		"saturday":  time.Saturday,
+	}
+
+	// months 月份名称
+	months = map[string]time.Month{
+		"january":   time.January,
+		"february":  time.February,
+		"march":     time.March,
+		"april":     time.April,
+		"may":       time.May,
+		"june":      time.June,
+		"july":      time.July,
+		"august":    time.August,
+		"september": time.September,
+		"october":   time.October,
+		"november":  time.November,
+		"december":  time.December,
+	}
+
+	// regexOffset 规则末尾的天数偏移，如 easter-2 或 4 thursday november+1
+	regexOffset = regexp.MustCompile(`^(easter|\S+ \S+ \S+)\s*([+-]\d+)$`)
+)
+
+// rule 按年计算节假日日期的规则
+type rule interface {
+	// 计算某年的日期
+	Date(year int) time.Time
+}
+
+// fixedRule 每年固定日期，如 01-01
+type fixedRule struct {
+	month time.Month
+	day   int
+}
+
+// Date 计算某年的日期
+func (r fixedRule) Date(year int) time.Time {
+	return time.Date(year, r.month, r.day, 0, 0, 0, 0, time.UTC)
+}
+
+// weekdayRule 某月第N个星期几，如 3 Monday January 或 last Monday May
+type weekdayRule struct {
+	nth     int // 第几个，-1表示最后一个
+	weekday time.Weekday
+	month   time.Month
+}
+
+// Date 计算某年的日期
+func (r weekdayRule) Date(year int) time.Time {
+
+	if r.nth < 0 {
+		// 下个月1日往前找
+		date := time.Date(year, r.month+1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+		for date.Weekday() != r.weekday {
+			date = date.AddDate(0, 0, -1)
+		}
+
+		return date
+	}
+
+	date := time.Date(year, r.month, 1, 0, 0, 0, 0, time.UTC)
+	for date.Weekday() != r.weekday {
+		date = date.AddDate(0, 0, 1)
+	}
+
+	return date.AddDate(0, 0, 7*(r.nth-1))
+}
+
+// easterRule 复活节(西方教会)
+type easterRule struct{}
+
+// Date 计算某年的复活节日期(Anonymous Gregorian algorithm)
+func (r easterRule) Date(year int) time.Time {
+
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+// offsetRule 在另一规则的基础上偏移若干天，如 easter-2
+type offsetRule struct {
+	rule
+	days int
+}
+
+// Date 计算某年的日期
+func (r offsetRule) Date(year int) time.Time {
+	return r.rule.Date(year).AddDate(0, 0, r.days)
+}
+
+// parseRule 解析规则
+func parseRule(text string) (rule, error) {
+
+	text = strings.ToLower(strings.TrimSpace(text))
+
+	// 末尾的天数偏移(仅用于easter及星期规则，固定日期本身含有"-")
+	var days int
+	if matches := regexOffset.FindStringSubmatch(text); len(matches) == 3 {
+		offset, err := strconv.Atoi(matches[2])
+		if err != nil {
+			return nil, fmt.Errorf("错误的节假日规则偏移:%s", text)
+		}
+
+		text, days = strings.TrimSpace(matches[1]), offset
+	}
+
+	base, err := parseBaseRule(text)
+	if err != nil {
+		return nil, err
+	}
+
+	if days == 0 {
+		return base, nil
+	}
+
+	return offsetRule{base, days}, nil
+}
+
+// parseBaseRule 解析不含偏移的规则
+func parseBaseRule(text string) (rule, error) {
+
+	if text == "easter" {
+		return easterRule{}, nil
+	}
+
+	// 固定日期 01-01
+	if date, err := time.Parse("01-02", text); err == nil {
+		return fixedRule{date.Month(), date.Day()}, nil
+	}
+
+	// 某月第N个星期几 3 monday january
+	parts := strings.Fields(text)
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("错误的节假日规则:%s", text)
+	}
+
+	nth := -1
+	if parts[0] != "last" {
+		n, err := strconv.Atoi(parts[0])
+		if err != nil || n < 1 || n > 5 {
+			return nil, fmt.Errorf("错误的节假日规则序号:%s", text)
+		}
+		nth = n
+	}
+
+	weekday, found := weekdays[parts[1]]
+	if !found {
+		return nil, fmt.Errorf("错误的节假日规则星期:%s", text)
+	}
+
+	month, found := months[parts[2]]
+	if !found {
+		return nil, fmt.Errorf("错误的节假日规则月份:%s", text)
+	}
+
+	return weekdayRule{nth, weekday, month}, nil
+}
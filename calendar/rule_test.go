@@ -0,0 +1,54 @@
+package calendar
+
+import (
+	"testing"
+)
+
+func TestRules(t *testing.T) {
+
+	tests := []struct {
+		rule string
+		year int
+		date string
+	}{
+		// 复活节
+		{"easter", 2018, "2018-04-01"},
+		{"easter", 2019, "2019-04-21"},
+		{"easter", 2024, "2024-03-31"},
+		{"easter", 2025, "2025-04-20"},
+		{"easter-2", 2019, "2019-04-19"},
+		{"easter+1", 2021, "2021-04-05"},
+		// 某月第N个星期几
+		{"3 Monday January", 2018, "2018-01-15"},
+		{"1 Monday September", 2019, "2019-09-02"},
+		{"4 Thursday November", 2018, "2018-11-22"},
+		{"4 Thursday November+1", 2018, "2018-11-23"},
+		{"last Monday May", 2018, "2018-05-28"},
+		{"last Monday May", 2021, "2021-05-31"},
+		{"last Monday August", 2020, "2020-08-31"},
+		// 固定日期
+		{"07-04", 2020, "2020-07-04"},
+	}
+
+	for _, test := range tests {
+
+		r, err := parseRule(test.rule)
+		if err != nil {
+			t.Errorf("%s: %v", test.rule, err)
+			continue
+		}
+
+		if date := r.Date(test.year).Format(datePattern); date != test.date {
+			t.Errorf("%s在%d年为%s，应为%s", test.rule, test.year, date, test.date)
+		}
+	}
+}
+
+func TestParseRuleErrors(t *testing.T) {
+
+	for _, text := range []string{"", "13-01", "6 Monday May", "1 Someday May", "1 Monday Smarch", "easter*2"} {
+		if _, err := parseRule(text); err == nil {
+			t.Errorf("%s应为错误的规则", text)
+		}
+	}
+}
@@ -0,0 +1,509 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nzai/stockrecorder/adjust"
+	"github.com/nzai/stockrecorder/journal"
+	"github.com/nzai/stockrecorder/market"
+	"github.com/nzai/stockrecorder/notify"
+	"github.com/nzai/stockrecorder/recorder"
+)
+
+const (
+	// commandDatePattern 命令行参数的日期格式
+	commandDatePattern = "20060102"
+)
+
+// command 子命令
+type command struct {
+	usage string                    // 说明
+	run   func(args []string) error // 执行
+}
+
+var (
+	// commands 子命令
+	commands = map[string]command{
+		"backfill": {"补录指定市场一段时间的数据", backfill},
+		"diff":     {"比较指定市场两天的上市公司列表", diff},
+		"verify":   {"校验指定市场一段时间已保存的数据，可以重新抓取缺失的公司", verify},
+		"notify":   {"发送一条测试通知，检查通知配置", notifyTest},
+		"journal":  {"查询抓取记录", listJournal},
+		"events":   {"查询公司一段时间的分红、拆股及财报事件", listEvents},
+		"adjusted": {"查询公司一段时间的复权报价", listAdjusted},
+	}
+)
+
+// backfill 补录指定市场一段时间的数据
+func backfill(args []string) error {
+
+	flags := flag.NewFlagSet("backfill", flag.ExitOnError)
+	configPath := flags.String("config", "", "配置文件路径，默认为执行文件所在目录下的config.yaml")
+	marketNames := flags.String("markets", "", "市场名称，多个用逗号分隔，如 america,china")
+	start := flags.String("start", "", "起始日期(含)，如 20180102")
+	end := flags.String("end", "", "结束日期(含)，默认与起始日期相同")
+	codes := flags.String("companies", "", "只补录这些公司代码，多个用逗号分隔，默认补录全部上市公司；只补录已有数据的日期(总是覆盖这些公司)，并保留当天其他公司的数据")
+	force := flags.Bool("force", false, "补录全部上市公司时覆盖已存在的数据")
+	flags.Parse(args)
+
+	// 先读取配置，自定义市场注册后才能按名称获取
+	config, err := parseConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	markets, err := parseMarkets(*marketNames)
+	if err != nil {
+		return err
+	}
+
+	if *end == "" {
+		*end = *start
+	}
+
+	options := recorder.BackfillOptions{Codes: splitList(*codes), Force: *force}
+	options.Start, err = time.Parse(commandDatePattern, *start)
+	if err != nil {
+		return fmt.Errorf("错误的起始日期:%s", *start)
+	}
+
+	options.End, err = time.Parse(commandDatePattern, *end)
+	if err != nil {
+		return fmt.Errorf("错误的结束日期:%s", *end)
+	}
+
+	r, err := newRecorder(config)
+	if err != nil {
+		return err
+	}
+	ctx := signalContext()
+	for _, _market := range markets {
+
+		err = r.Backfill(ctx, _market, options)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Print("补录结束")
+
+	return nil
+}
+
+// diff 比较指定市场两天的上市公司列表
+func diff(args []string) error {
+
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	configPath := flags.String("config", "", "配置文件路径，默认为执行文件所在目录下的config.yaml")
+	marketNames := flags.String("markets", "", "市场名称，多个用逗号分隔，如 america,china")
+	from := flags.String("from", "", "较早的日期，如 20180102")
+	to := flags.String("to", "", "较晚的日期，如 20180103")
+	flags.Parse(args)
+
+	// 先读取配置，自定义市场注册后才能按名称获取
+	config, err := parseConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	markets, err := parseMarkets(*marketNames)
+	if err != nil {
+		return err
+	}
+
+	fromDate, err := time.Parse(commandDatePattern, *from)
+	if err != nil {
+		return fmt.Errorf("错误的起始日期:%s", *from)
+	}
+
+	toDate, err := time.Parse(commandDatePattern, *to)
+	if err != nil {
+		return fmt.Errorf("错误的结束日期:%s", *to)
+	}
+
+	r, err := newRecorder(config)
+	if err != nil {
+		return err
+	}
+	ctx := signalContext()
+	for _, _market := range markets {
+
+		d, err := r.DiffCompanies(ctx, _market, fromDate, toDate)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("[%s] %s -> %s: %s\n", _market.Name(), *from, *to, d.String())
+		for _, company := range d.Added {
+			fmt.Printf("+ %s\t%s\n", company.Code, company.Name)
+		}
+		for _, company := range d.Removed {
+			fmt.Printf("- %s\t%s\n", company.Code, company.Name)
+		}
+		for _, rename := range d.Renamed {
+			fmt.Printf("~ %s\t%s -> %s\n", rename.Code, rename.OldName, rename.NewName)
+		}
+	}
+
+	return nil
+}
+
+// verify 校验指定市场一段时间已保存的数据
+func verify(args []string) error {
+
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	configPath := flags.String("config", "", "配置文件路径，默认为执行文件所在目录下的config.yaml")
+	marketNames := flags.String("markets", "", "市场名称，多个用逗号分隔，如 america,china")
+	start := flags.String("start", "", "起始日期(含)，如 20180102")
+	end := flags.String("end", "", "结束日期(含)，默认与起始日期相同")
+	ratio := flags.Float64("ratio", 0, "盘中报价数低于该公司近期(前5个有数据的交易日)水平的此比例时视为不完整，默认0.5，为负数时不检查")
+	repair := flags.Bool("repair", false, "重新抓取缺失及不完整的公司(只限数据源有效期内的日期)")
+	flags.Parse(args)
+
+	// 先读取配置，自定义市场注册后才能按名称获取
+	config, err := parseConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	markets, err := parseMarkets(*marketNames)
+	if err != nil {
+		return err
+	}
+
+	if *end == "" {
+		*end = *start
+	}
+
+	options := recorder.VerifyOptions{MinBarRatio: *ratio, Repair: *repair}
+	options.Start, err = time.Parse(commandDatePattern, *start)
+	if err != nil {
+		return fmt.Errorf("错误的起始日期:%s", *start)
+	}
+
+	options.End, err = time.Parse(commandDatePattern, *end)
+	if err != nil {
+		return fmt.Errorf("错误的结束日期:%s", *end)
+	}
+
+	r, err := newRecorder(config)
+	if err != nil {
+		return err
+	}
+
+	ctx := signalContext()
+	for _, _market := range markets {
+
+		verifications, err := r.Verify(ctx, _market, options)
+		if err != nil {
+			return err
+		}
+
+		incomplete := 0
+		for _, v := range verifications {
+			if !v.OK() {
+				incomplete++
+			}
+		}
+
+		fmt.Printf("[%s] 共校验%d个交易日，%d个不完整\n", _market.Name(), len(verifications), incomplete)
+		for _, v := range verifications {
+			if v.OK() {
+				continue
+			}
+
+			fmt.Printf("[%s] %s\n", _market.Name(), v.String())
+			for _, company := range v.Missing {
+				fmt.Printf("  缺失 %s\t%s\n", company.Code, company.Name)
+			}
+			for _, company := range v.Truncated {
+				fmt.Printf("  不完整 %s\t%s\n", company.Code, company.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// notifyTest 发送一条测试通知
+func notifyTest(args []string) error {
+
+	flags := flag.NewFlagSet("notify", flag.ExitOnError)
+	configPath := flags.String("config", "", "配置文件路径，默认为执行文件所在目录下的config.yaml")
+	eventType := flags.String("type", string(notify.DayCompleted), "事件类型: day_completed、day_failed、high_failure_rate、companies_failed、companies_changed")
+	marketName := flags.String("market", "test", "市场名称")
+	flags.Parse(args)
+
+	config, err := parseConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	event := notify.NewEvent(notify.EventType(*eventType), *marketName, time.Now(), "这是一条测试通知")
+	err = notify.New(config.Recorder.Notify).Notify(context.Background(), event)
+	if err != nil {
+		return err
+	}
+
+	log.Print("测试通知已发送")
+
+	return nil
+}
+
+// listJournal 查询抓取记录
+func listJournal(args []string) error {
+
+	flags := flag.NewFlagSet("journal", flag.ExitOnError)
+	configPath := flags.String("config", "", "配置文件路径，默认为执行文件所在目录下的config.yaml")
+	marketNames := flags.String("markets", "", "市场名称，多个用逗号分隔，如 america,china，默认全部市场(只限本地文件抓取记录)")
+	start := flags.String("start", "", "起始日期(含)，如 20180102")
+	end := flags.String("end", "", "结束日期(含)，默认与起始日期相同")
+	result := flags.String("result", "", "只列出此结果的记录: success、failed、canceled")
+	sourceName := flags.String("source", "", "只列出使用过此数据源的记录，如 yahoo")
+	asJSON := flags.Bool("json", false, "每条记录输出一行json")
+	flags.Parse(args)
+
+	// 先读取配置，自定义市场注册后才能按名称获取
+	config, err := parseConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	filter := journal.Filter{Result: *result, Source: *sourceName}
+	if *marketNames != "" {
+		filter.Markets, err = parseMarkets(*marketNames)
+		if err != nil {
+			return err
+		}
+	}
+
+	if *end == "" {
+		*end = *start
+	}
+
+	if *start != "" {
+		filter.Start, err = time.Parse(commandDatePattern, *start)
+		if err != nil {
+			return fmt.Errorf("错误的起始日期:%s", *start)
+		}
+
+		filter.End, err = time.Parse(commandDatePattern, *end)
+		if err != nil {
+			return fmt.Errorf("错误的结束日期:%s", *end)
+		}
+	}
+
+	r, err := newRecorder(config)
+	if err != nil {
+		return err
+	}
+
+	runs, err := r.Journal(signalContext(), filter)
+	if err != nil {
+		return err
+	}
+
+	for _, run := range runs {
+
+		if !*asJSON {
+			fmt.Println(run.String())
+			continue
+		}
+
+		buffer, err := json.Marshal(run)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(buffer))
+	}
+
+	return nil
+}
+
+// listEvents 查询公司一段时间的分红、拆股及财报事件
+func listEvents(args []string) error {
+
+	flags := flag.NewFlagSet("events", flag.ExitOnError)
+	configPath := flags.String("config", "", "配置文件路径，默认为执行文件所在目录下的config.yaml")
+	marketNames := flags.String("markets", "", "市场名称，多个用逗号分隔，如 america,china")
+	code := flags.String("company", "", "公司代码，如 AAPL，默认全部公司")
+	start := flags.String("start", "", "起始日期(含)，如 20180102")
+	end := flags.String("end", "", "结束日期(含)，默认与起始日期相同")
+	asJSON := flags.Bool("json", false, "每个事件输出一行json")
+	flags.Parse(args)
+
+	// 先读取配置，自定义市场注册后才能按名称获取
+	config, err := parseConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	markets, err := parseMarkets(*marketNames)
+	if err != nil {
+		return err
+	}
+
+	if *end == "" {
+		*end = *start
+	}
+
+	startDate, err := time.Parse(commandDatePattern, *start)
+	if err != nil {
+		return fmt.Errorf("错误的起始日期:%s", *start)
+	}
+
+	endDate, err := time.Parse(commandDatePattern, *end)
+	if err != nil {
+		return fmt.Errorf("错误的结束日期:%s", *end)
+	}
+
+	r, err := newRecorder(config)
+	if err != nil {
+		return err
+	}
+
+	ctx := signalContext()
+	for _, _market := range markets {
+
+		events, err := r.Events(ctx, _market, *code, startDate, endDate)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+
+			if !*asJSON {
+				fmt.Printf("[%s] %s\n", _market.Name(), event.String())
+				continue
+			}
+
+			buffer, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(buffer))
+		}
+	}
+
+	return nil
+}
+
+// listAdjusted 查询公司一段时间的前复权报价
+func listAdjusted(args []string) error {
+
+	flags := flag.NewFlagSet("adjusted", flag.ExitOnError)
+	configPath := flags.String("config", "", "配置文件路径，默认为执行文件所在目录下的config.yaml")
+	marketName := flags.String("market", "", "市场名称，如 america")
+	code := flags.String("company", "", "公司代码，如 AAPL")
+	start := flags.String("start", "", "起始日期(含)，如 20180102")
+	end := flags.String("end", "", "结束日期(含)，默认与起始日期相同，复权以此日期为基准")
+	mode := flags.String("mode", "", "复权方式: none、split、dividend，默认使用配置的recorder.adjust")
+	asJSON := flags.Bool("json", false, "每天输出一行json")
+	flags.Parse(args)
+
+	// 先读取配置，自定义市场注册后才能按名称获取
+	config, err := parseConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	_market, err := market.Get(*marketName)
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, *marketName)
+	}
+
+	if *code == "" {
+		return fmt.Errorf("需要指定公司代码")
+	}
+
+	if *end == "" {
+		*end = *start
+	}
+
+	startDate, err := time.Parse(commandDatePattern, *start)
+	if err != nil {
+		return fmt.Errorf("错误的起始日期:%s", *start)
+	}
+
+	endDate, err := time.Parse(commandDatePattern, *end)
+	if err != nil {
+		return fmt.Errorf("错误的结束日期:%s", *end)
+	}
+
+	r, err := newRecorder(config)
+	if err != nil {
+		return err
+	}
+
+	days, err := r.Adjusted(signalContext(), _market, *code, startDate, endDate, *mode)
+	if err != nil {
+		return err
+	}
+
+	for _, day := range days {
+
+		if *asJSON {
+			buffer, err := json.Marshal(day)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(buffer))
+			continue
+		}
+
+		for _, serie := range []struct {
+			name   string
+			series adjust.Series
+		}{{"pre", day.Pre}, {"regular", day.Regular}, {"post", day.Post}} {
+			for index, timestamp := range serie.series.Timestamp {
+				fmt.Printf("%s\t%s\t%.4f\t%.4f\t%.4f\t%.4f\t%.0f\n",
+					time.Unix(int64(timestamp), 0).In(day.Date.Location()).Format("2006-01-02 15:04"), serie.name,
+					serie.series.Open[index], serie.series.Close[index], serie.series.Max[index], serie.series.Min[index], serie.series.Volume[index])
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseMarkets 解析市场名称列表
+func parseMarkets(names string) ([]market.Market, error) {
+
+	var markets []market.Market
+	for _, name := range splitList(names) {
+
+		_market, err := market.Get(name)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %s", err, name)
+		}
+
+		markets = append(markets, _market)
+	}
+
+	if len(markets) == 0 {
+		return nil, fmt.Errorf("没有指定市场")
+	}
+
+	return markets, nil
+}
+
+// splitList 拆分逗号分隔的列表
+func splitList(text string) []string {
+
+	var items []string
+	for _, item := range strings.Split(text, ",") {
+
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
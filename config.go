@@ -1,33 +1,78 @@
 package main
 
 import (
+	"fmt"
 	"log"
-	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/nzai/go-utility/io"
 	"github.com/nzai/go-utility/path"
 	yaml "gopkg.in/yaml.v2"
 
+	"github.com/nzai/stockrecorder/calendar"
+	"github.com/nzai/stockrecorder/journal"
+	"github.com/nzai/stockrecorder/lease"
+	"github.com/nzai/stockrecorder/market"
+	"github.com/nzai/stockrecorder/recorder"
+	"github.com/nzai/stockrecorder/source"
 	"github.com/nzai/stockrecorder/store"
 )
 
 var (
 	// defaultConfigFileName 默认的配置文件名
 	defaultConfigFileName = "config.yaml"
+	// defaultMarkets 没有配置时运行的市场: 美股、A股、港股
+	defaultMarkets = []string{"america", "china", "hongkong"}
 )
 
 // Config 配置
 type Config struct {
-	Aliyun struct {
+	Markets    []string                          `yaml:"markets"`    // 运行的市场
+	Custom     []market.CustomConfig             `yaml:"custom"`     // 自定义市场
+	Calendar   string                            `yaml:"calendar"`   // 交易日历文件，覆盖同名市场的内置日历
+	Watchlists map[string]market.WatchlistConfig `yaml:"watchlists"` // 各市场从本地文件读取的上市公司
+	Recorder   recorder.Config                   `yaml:"recorder"`
+	Sources    source.ChainConfig                `yaml:"sources"`
+	Limits     map[string]source.LimiterConfig   `yaml:"limits"`
+	Symbols    map[string]source.SymbolConfig    `yaml:"symbols"` // 各数据源(yahoo)的查询代码规则
+	Lease      LeaseConfig                       `yaml:"lease"`
+	Journal    journal.Config                    `yaml:"journal"`
+	HTTP       HTTPConfig                        `yaml:"http"`
+	Aliyun     struct {
 		OSS store.AliyunOSSConfig `yaml:"oss"`
 	} `yaml:"aliyun"`
 }
 
-// parseConfig 解析配置
-func parseConfig() (*Config, error) {
+// LeaseConfig 多实例协调配置
+type LeaseConfig struct {
+	Type  string            `yaml:"type"`  // redis: 多台主机共用Redis；file: 同一台主机上的文件锁；为空时不协调(单实例)
+	Dir   string            `yaml:"dir"`   // 文件锁目录
+	Redis lease.RedisConfig `yaml:"redis"` // Redis配置
+}
+
+// locker 按配置创建租约管理
+func (c LeaseConfig) locker() (lease.Locker, error) {
+
+	switch c.Type {
+	case "":
+		return nil, nil
+	case "redis":
+		return lease.NewRedis(c.Redis), nil
+	case "file":
+		if c.Dir == "" {
+			return nil, fmt.Errorf("文件锁需要配置目录lease.dir")
+		}
+		return lease.NewFile(c.Dir), nil
+	default:
+		return nil, fmt.Errorf("未知的租约类型:%s", c.Type)
+	}
+}
+
+// parseConfig 解析配置，filePath为空时使用默认的配置文件
+func parseConfig(filePath string) (*Config, error) {
 
-	configPath, err := getConfigFilePath()
+	configPath, err := getConfigFilePath(filePath)
 	if err != nil {
 		return nil, err
 	}
@@ -43,16 +88,111 @@ func parseConfig() (*Config, error) {
 	//	解析配置项
 	config := new(Config)
 	err = yaml.Unmarshal(buffer, config)
+	if err != nil {
+		return nil, err
+	}
+
+	//	交易日历需要在校验定时任务前加载
+	if config.Calendar != "" {
+		err = calendar.LoadFile(config.Calendar)
+		if err != nil {
+			return nil, fmt.Errorf("加载交易日历%s时发生错误: %v", config.Calendar, err)
+		}
+	}
+
+	//	注册自定义市场
+	for _, c := range config.Custom {
+
+		custom, err := market.NewCustom(c)
+		if err != nil {
+			return nil, err
+		}
+
+		market.Register(custom)
+	}
+
+	//	从本地文件读取上市公司的市场，替换注册表中的同名市场
+	for name, c := range config.Watchlists {
+
+		_market, err := market.Get(name)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %s", err, name)
+		}
+
+		watchlist, err := market.NewWatchlist(_market, c)
+		if err != nil {
+			return nil, err
+		}
+
+		market.Register(watchlist)
+	}
+
+	//	校验配置项
+	err = config.Recorder.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	for name, symbols := range config.Symbols {
+		err = symbols.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("数据源%s的查询代码配置错误: %v", name, err)
+		}
+	}
+
+	return config, nil
+}
+
+// yahooSymbols 雅虎财经的查询代码配置，自定义市场的suffix作为该市场的后缀(symbols中配置的优先)
+func (c Config) yahooSymbols() source.SymbolConfig {
+
+	configured := c.Symbols["yahoo"]
+	symbols := source.SymbolConfig{
+		Suffixes:  make(map[string]string),
+		Overrides: configured.Overrides,
+	}
+
+	for _, custom := range c.Custom {
+		if custom.Suffix != "" {
+			symbols.Suffixes[strings.ToLower(custom.Name)] = custom.Suffix
+		}
+	}
+
+	for name, suffix := range configured.Suffixes {
+		symbols.Suffixes[strings.ToLower(name)] = suffix
+	}
+
+	return symbols
+}
+
+// markets 运行的市场
+func (c Config) markets() ([]market.Market, error) {
+
+	names := c.Markets
+	if len(names) == 0 {
+		names = defaultMarkets
+	}
+
+	markets := make([]market.Market, 0, len(names))
+	for _, name := range names {
+
+		_market, err := market.Get(name)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %s", err, name)
+		}
+
+		markets = append(markets, _market)
+	}
 
-	return config, err
+	return markets, nil
 }
 
 // getConfigFilePath 获取配置文件路径
-func getConfigFilePath() (string, error) {
+func getConfigFilePath(filePath string) (string, error) {
 
-	if len(os.Args) > 1 {
+	if filePath != "" {
 		// 指定了配置文件
-		return os.Args[1], nil
+		return filePath, nil
 	}
 
 	// 获取启动路径，默认情况配置文件和执行文件放在同一目录
@@ -0,0 +1,181 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/nzai/stockrecorder/market"
+)
+
+// Config 上市公司筛选配置，各条件同时满足才保留
+type Config struct {
+	Include   []string `yaml:"include"`   // 代码正则，配置时只保留满足其中之一的公司
+	Exclude   []string `yaml:"exclude"`   // 代码正则，排除满足其中之一的公司
+	Types     []string `yaml:"types"`     // 证券类型，如 stock、etp，配置时只保留这些类型(没有类型信息的公司也被排除)
+	Exchanges []string `yaml:"exchanges"` // 交易所，如 NASDAQ，配置时只保留这些交易所的公司
+	Allow     []string `yaml:"allow"`     // 总是保留的公司代码，不受其他条件限制
+	Deny      []string `yaml:"deny"`      // 总是排除的公司代码，优先于allow
+	TopVolume int      `yaml:"topvolume"` // 只保留前一交易日成交量最大的N家公司，0为不限，前一交易日没有数据时不限
+}
+
+// Validate 校验配置
+func (c Config) Validate() error {
+	_, err := New(c)
+	return err
+}
+
+// Empty 是否没有任何筛选条件
+func (c Config) Empty() bool {
+	return len(c.Include) == 0 && len(c.Exclude) == 0 && len(c.Types) == 0 && len(c.Exchanges) == 0 &&
+		len(c.Deny) == 0 && c.TopVolume <= 0
+}
+
+// Filter 上市公司筛选
+type Filter struct {
+	include   []*regexp.Regexp
+	exclude   []*regexp.Regexp
+	types     map[string]bool
+	exchanges map[string]bool
+	allow     map[string]bool
+	deny      map[string]bool
+	topVolume int
+}
+
+// New 新建上市公司筛选
+func New(config Config) (*Filter, error) {
+
+	f := &Filter{
+		types:     lowerSet(config.Types),
+		exchanges: lowerSet(config.Exchanges),
+		allow:     lowerSet(config.Allow),
+		deny:      lowerSet(config.Deny),
+		topVolume: config.TopVolume,
+	}
+
+	var err error
+	f.include, err = compile(config.Include)
+	if err != nil {
+		return nil, err
+	}
+
+	f.exclude, err = compile(config.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.TopVolume < 0 {
+		return nil, fmt.Errorf("错误的成交量排名:%d", config.TopVolume)
+	}
+
+	return f, nil
+}
+
+// TopVolume 需要保留的成交量最大的公司数，0为不限
+func (f Filter) TopVolume() int {
+	return f.topVolume
+}
+
+// Apply 筛选上市公司，volumes为前一交易日各公司(按代码)的成交量，为nil时不按成交量筛选，结果按Code排序
+func (f Filter) Apply(companies []market.Company, volumes map[string]uint64) []market.Company {
+
+	var allowed, matched []market.Company
+	for _, company := range companies {
+
+		code := strings.ToLower(company.Code)
+		if f.deny[code] {
+			continue
+		}
+
+		if f.allow[code] {
+			allowed = append(allowed, company)
+			continue
+		}
+
+		if f.match(company) {
+			matched = append(matched, company)
+		}
+	}
+
+	// 按前一交易日的成交量排名，成交量相同时按代码
+	if f.topVolume > 0 && volumes != nil && len(matched) > f.topVolume {
+		sort.SliceStable(matched, func(i, j int) bool {
+			vi, vj := volumes[matched[i].Code], volumes[matched[j].Code]
+			if vi != vj {
+				return vi > vj
+			}
+			return matched[i].Code < matched[j].Code
+		})
+		matched = matched[:f.topVolume]
+	}
+
+	selected := append(allowed, matched...)
+
+	//	按Code排序
+	sort.Sort(market.CompanyList(selected))
+
+	return selected
+}
+
+// match 是否满足代码、类型及交易所条件
+func (f Filter) match(company market.Company) bool {
+
+	if len(f.include) > 0 && !matchAny(f.include, company.Code) {
+		return false
+	}
+
+	if matchAny(f.exclude, company.Code) {
+		return false
+	}
+
+	if len(f.types) > 0 && !f.types[strings.ToLower(company.Type)] {
+		return false
+	}
+
+	if len(f.exchanges) > 0 && !f.exchanges[strings.ToLower(company.Exchange)] {
+		return false
+	}
+
+	return true
+}
+
+// compile 编译正则
+func compile(patterns []string) ([]*regexp.Regexp, error) {
+
+	regexps := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+
+		r, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("错误的代码正则%s: %v", pattern, err)
+		}
+
+		regexps = append(regexps, r)
+	}
+
+	return regexps, nil
+}
+
+// matchAny 是否满足其中之一
+func matchAny(regexps []*regexp.Regexp, code string) bool {
+
+	for _, r := range regexps {
+		if r.MatchString(code) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lowerSet 转为小写的集合
+func lowerSet(items []string) map[string]bool {
+
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[strings.ToLower(strings.TrimSpace(item))] = true
+	}
+
+	return set
+}
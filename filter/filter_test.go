@@ -0,0 +1,88 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nzai/stockrecorder/market"
+)
+
+// testCompanies 不同交易所、类型的上市公司
+func testCompanies() []market.Company {
+	return []market.Company{
+		{Code: "SPY", Exchange: "NYSEArca", Type: "etp"},
+		{Code: "AAPL", Exchange: "NASDAQ", Type: "stock"},
+		{Code: "MSFT", Exchange: "NASDAQ", Type: "stock"},
+		{Code: "IBM", Exchange: "NYSE", Type: "stock"},
+		{Code: "BRK-B", Exchange: "NYSE", Type: "stock"},
+		{Code: "QQQ", Exchange: "NASDAQ", Type: "etp"},
+		{Code: "ZZZ"},
+	}
+}
+
+// codes 公司代码，以逗号分隔
+func codes(companies []market.Company) string {
+
+	items := make([]string, 0, len(companies))
+	for _, company := range companies {
+		items = append(items, company.Code)
+	}
+
+	return strings.Join(items, ",")
+}
+
+func TestApply(t *testing.T) {
+
+	volumes := map[string]uint64{"SPY": 900, "AAPL": 500, "MSFT": 500, "IBM": 100, "BRK-B": 50, "QQQ": 700}
+
+	tests := []struct {
+		name     string
+		config   Config
+		volumes  map[string]uint64
+		expected string
+	}{
+		{"不筛选", Config{}, nil, "AAPL,BRK-B,IBM,MSFT,QQQ,SPY,ZZZ"},
+		{"代码正则", Config{Include: []string{"^[A-C]", "^Q"}, Exclude: []string{"-"}}, nil, "AAPL,QQQ"},
+		// 没有类型信息的公司也被排除，类型及交易所不区分大小写
+		{"类型", Config{Types: []string{"Stock"}}, nil, "AAPL,BRK-B,IBM,MSFT"},
+		{"交易所", Config{Exchanges: []string{"nasdaq"}, Types: []string{"etp"}}, nil, "QQQ"},
+		// allow不受其他条件限制，deny优先于allow
+		{"总是保留及排除", Config{Types: []string{"etp"}, Allow: []string{"ibm", "aapl"}, Deny: []string{"AAPL", "SPY"}}, nil, "IBM,QQQ"},
+		// 成交量相同时按代码，没有成交量的排在最后
+		{"成交量排名", Config{TopVolume: 3}, volumes, "AAPL,QQQ,SPY"},
+		{"成交量排名及其他条件", Config{Types: []string{"stock"}, TopVolume: 2}, volumes, "AAPL,MSFT"},
+		{"成交量排名不含总是保留的公司", Config{TopVolume: 1, Allow: []string{"ZZZ"}}, volumes, "SPY,ZZZ"},
+		// 前一交易日没有数据时不按成交量筛选
+		{"没有成交量", Config{TopVolume: 1}, nil, "AAPL,BRK-B,IBM,MSFT,QQQ,SPY,ZZZ"},
+	}
+
+	for _, test := range tests {
+
+		f, err := New(test.config)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		if selected := codes(f.Apply(testCompanies(), test.volumes)); selected != test.expected {
+			t.Errorf("%s: 保留了%s，应为%s", test.name, selected, test.expected)
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+
+	for _, config := range []Config{{Include: []string{"("}}, {Exclude: []string{"[a-"}}, {TopVolume: -1}} {
+		if err := config.Validate(); err == nil {
+			t.Errorf("%+v应为错误的配置", config)
+		}
+	}
+
+	if !(Config{Allow: []string{"AAPL"}}).Empty() {
+		t.Error("只有allow时不需要筛选")
+	}
+
+	if (Config{TopVolume: 10}).Empty() {
+		t.Error("按成交量排名时需要筛选")
+	}
+}
@@ -0,0 +1,88 @@
+package journal
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/nzai/stockrecorder/market"
+)
+
+// File 保存在本地文件中的抓取记录，每行一条json
+type File struct {
+	path  string
+	mutex *sync.Mutex
+}
+
+// NewFile 新建本地文件抓取记录
+func NewFile(path string) *File {
+	return &File{path: path, mutex: new(sync.Mutex)}
+}
+
+// Append 追加一次抓取的记录
+func (f File) Append(ctx context.Context, _market market.Market, run Run) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	line, err := marshal(run)
+	if err != nil {
+		return err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	err = os.MkdirAll(filepath.Dir(f.path), 0755)
+	if err != nil {
+		return err
+	}
+
+	// 每条记录一次写入，多个进程共用同一个文件时也不会交错
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(line)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// List 查询
+func (f File) List(ctx context.Context, filter Filter) ([]Run, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	runs, err := unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []Run
+	for _, run := range runs {
+		if filter.Match(run) {
+			matched = append(matched, run)
+		}
+	}
+	sortRuns(matched)
+
+	return matched, nil
+}
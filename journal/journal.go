@@ -0,0 +1,206 @@
+package journal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nzai/stockrecorder/market"
+	"github.com/nzai/stockrecorder/store"
+)
+
+const (
+	datePattern = "20060102"
+)
+
+const (
+	// Success 已保存
+	Success = "success"
+	// Failed 抓取或保存失败
+	Failed = "failed"
+	// Canceled 已取消(未保存)
+	Canceled = "canceled"
+)
+
+// Run 一次抓取的记录
+type Run struct {
+	Market    string         `json:"market"`            // 市场
+	Date      string         `json:"date"`              // 交易日，如20180102
+	Start     time.Time      `json:"start"`             // 开始时间
+	Duration  float64        `json:"duration"`          // 耗时(秒)
+	Result    string         `json:"result"`            // 结果: success、failed、canceled
+	Error     string         `json:"error,omitempty"`   // 错误
+	Total     int            `json:"total"`             // 公司总数
+	Succeeded int            `json:"succeeded"`         // 成功数
+	Failed    int            `json:"failed"`            // 失败数
+	Passes    int            `json:"passes"`            // 抓取轮数(含首轮)
+	Sources   map[string]int `json:"sources,omitempty"` // 各数据源抓取成功的公司数
+	Bytes     int            `json:"bytes"`             // 写入存储的字节数(文件及对象存储为压缩后的大小)
+	Repair    bool           `json:"repair,omitempty"`  // 是否为校验后重新抓取部分公司
+	Host      string         `json:"host,omitempty"`    // 执行抓取的主机
+	Version   string         `json:"version"`           // 记录器版本
+}
+
+// String 显示
+func (r Run) String() string {
+
+	text := fmt.Sprintf("[%s] %s %s %s 耗时%.1fs 共%d家 成功%d家 失败%d家 %d轮 %d字节 %s",
+		r.Market, r.Date, r.Start.Local().Format("2006-01-02 15:04:05"), r.Result, r.Duration,
+		r.Total, r.Succeeded, r.Failed, r.Passes, r.Bytes, r.sources())
+
+	if r.Repair {
+		text += " 重新抓取"
+	}
+
+	if r.Error != "" {
+		text += " 错误: " + r.Error
+	}
+
+	return text
+}
+
+// sources 各数据源的公司数
+func (r Run) sources() string {
+
+	names := make([]string, 0, len(r.Sources))
+	for name := range r.Sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	items := make([]string, 0, len(names))
+	for _, name := range names {
+		items = append(items, fmt.Sprintf("%s:%d", name, r.Sources[name]))
+	}
+
+	return strings.Join(items, ",")
+}
+
+// Filter 查询条件
+type Filter struct {
+	Markets []market.Market // 市场，为空时不限
+	Start   time.Time       // 起始日期(含)，为零时不限
+	End     time.Time       // 结束日期(含)，为零时不限
+	Result  string          // 结果，为空时不限
+	Source  string          // 使用过的数据源，为空时不限
+}
+
+// Match 是否符合查询条件
+func (f Filter) Match(run Run) bool {
+
+	if len(f.Markets) > 0 {
+		found := false
+		for _, _market := range f.Markets {
+			if strings.EqualFold(_market.Name(), run.Market) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if !f.Start.IsZero() && run.Date < f.Start.Format(datePattern) {
+		return false
+	}
+
+	if !f.End.IsZero() && run.Date > f.End.Format(datePattern) {
+		return false
+	}
+
+	if f.Result != "" && f.Result != run.Result {
+		return false
+	}
+
+	if f.Source != "" && run.Sources[f.Source] == 0 {
+		return false
+	}
+
+	return true
+}
+
+// Journal 抓取记录
+type Journal interface {
+	// 追加一次抓取的记录
+	Append(ctx context.Context, _market market.Market, run Run) error
+	// 查询，结果按日期、市场、开始时间排序
+	List(ctx context.Context, filter Filter) ([]Run, error)
+}
+
+// Config 抓取记录配置
+type Config struct {
+	Type string `yaml:"type"` // file: 本地文件；store: 与当天数据一起保存在存储中；为空时不记录
+	Path string `yaml:"path"` // 本地文件路径
+}
+
+// New 按配置新建抓取记录，没有配置时返回nil
+func New(config Config, s store.Store) (Journal, error) {
+
+	switch config.Type {
+	case "":
+		return nil, nil
+	case "file":
+		if config.Path == "" {
+			return nil, fmt.Errorf("本地文件抓取记录需要配置路径journal.path")
+		}
+		return NewFile(config.Path), nil
+	case "store":
+		return NewStore(s), nil
+	default:
+		return nil, fmt.Errorf("未知的抓取记录类型:%s", config.Type)
+	}
+}
+
+// marshal 编码为一行json
+func marshal(run Run) ([]byte, error) {
+
+	buffer, err := json.Marshal(run)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(buffer, '\n'), nil
+}
+
+// unmarshal 解码json lines，忽略空行
+func unmarshal(data []byte) ([]Run, error) {
+
+	var runs []Run
+	for _, line := range strings.Split(string(data), "\n") {
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		var run Run
+		err := json.Unmarshal([]byte(line), &run)
+		if err != nil {
+			return nil, fmt.Errorf("错误的抓取记录 %s: %v", line, err)
+		}
+
+		runs = append(runs, run)
+	}
+
+	return runs, nil
+}
+
+// sortRuns 按日期、市场、开始时间排序
+func sortRuns(runs []Run) {
+	sort.SliceStable(runs, func(i, j int) bool {
+		if runs[i].Date != runs[j].Date {
+			return runs[i].Date < runs[j].Date
+		}
+
+		if runs[i].Market != runs[j].Market {
+			return runs[i].Market < runs[j].Market
+		}
+
+		return runs[i].Start.Before(runs[j].Start)
+	})
+}
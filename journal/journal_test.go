@@ -0,0 +1,156 @@
+package journal
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nzai/stockrecorder/market"
+	"github.com/nzai/stockrecorder/store"
+)
+
+// testRuns 两个市场三天的抓取记录，按追加顺序
+func testRuns() []Run {
+
+	start := time.Date(2018, 1, 4, 22, 0, 0, 0, time.UTC)
+
+	return []Run{
+		{Market: "America", Date: "20180103", Start: start.Add(time.Hour), Result: Success, Sources: map[string]int{"yahoo": 10}, Bytes: 1024},
+		{Market: "America", Date: "20180102", Start: start.Add(time.Minute), Result: Failed, Error: "所有数据源都获取失败", Sources: map[string]int{"yahoo": 3}},
+		{Market: "America", Date: "20180102", Start: start, Result: Canceled},
+		{Market: "China", Date: "20180102", Start: start, Result: Success, Sources: map[string]int{"sina": 8}, Repair: true},
+	}
+}
+
+// checkRuns 检查查询结果的日期、市场及结果
+func checkRuns(t *testing.T, runs []Run, expected ...string) {
+
+	if len(runs) != len(expected) {
+		t.Fatalf("查询到%d条记录，应为%d条: %v", len(runs), len(expected), runs)
+	}
+
+	for index, run := range runs {
+		if text := run.Date + " " + run.Market + " " + run.Result; text != expected[index] {
+			t.Errorf("第%d条记录为%s，应为%s", index, text, expected[index])
+		}
+	}
+}
+
+func TestFile(t *testing.T) {
+
+	ctx := context.Background()
+	j := NewFile(filepath.Join(t.TempDir(), "journal", "journal.jsonl"))
+
+	// 文件不存在时没有记录
+	runs, err := j.List(ctx, Filter{})
+	if err != nil || len(runs) != 0 {
+		t.Fatalf("文件不存在时应没有记录: %v %v", runs, err)
+	}
+
+	for _, run := range testRuns() {
+		err = j.Append(ctx, market.America{}, run)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 按日期、市场、开始时间排序
+	runs, err = j.List(ctx, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRuns(t, runs, "20180102 America canceled", "20180102 America failed", "20180102 China success", "20180103 America success")
+
+	// 解码后与追加的相同
+	if runs[1].Error != "所有数据源都获取失败" || runs[2].Sources["sina"] != 8 || !runs[2].Repair || runs[3].Bytes != 1024 {
+		t.Errorf("读取的记录与追加的不一致: %+v", runs)
+	}
+
+	tests := []struct {
+		filter   Filter
+		expected []string
+	}{
+		{Filter{Markets: []market.Market{market.China{}}}, []string{"20180102 China success"}},
+		{Filter{Start: time.Date(2018, 1, 3, 0, 0, 0, 0, time.UTC)}, []string{"20180103 America success"}},
+		{Filter{End: time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC), Result: Failed}, []string{"20180102 America failed"}},
+		{Filter{Source: "yahoo"}, []string{"20180102 America failed", "20180103 America success"}},
+	}
+
+	for _, test := range tests {
+
+		runs, err = j.List(ctx, test.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkRuns(t, runs, test.expected...)
+	}
+}
+
+func TestStore(t *testing.T) {
+
+	ctx := context.Background()
+	j := NewStore(store.NewFileSystem(store.FileSystemConfig{StoreRoot: t.TempDir()}))
+
+	for _, run := range testRuns() {
+
+		_market := market.Market(market.America{})
+		if run.Market == "China" {
+			_market = market.China{}
+		}
+
+		err := j.Append(ctx, _market, run)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 需要指定市场及起止日期
+	_, err := j.List(ctx, Filter{Markets: []market.Market{market.America{}}})
+	if err == nil {
+		t.Error("没有指定起止日期时应返回错误")
+	}
+
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2018, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	runs, err := j.List(ctx, Filter{Markets: []market.Market{market.America{}, market.China{}}, Start: start, End: end})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRuns(t, runs, "20180102 America canceled", "20180102 America failed", "20180102 China success", "20180103 America success")
+
+	runs, err = j.List(ctx, Filter{Markets: []market.Market{market.America{}}, Start: start, End: end, Result: Success})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRuns(t, runs, "20180103 America success")
+}
+
+func TestNew(t *testing.T) {
+
+	s := store.NewFileSystem(store.FileSystemConfig{StoreRoot: t.TempDir()})
+
+	j, err := New(Config{}, s)
+	if err != nil || j != nil {
+		t.Errorf("没有配置时不记录: %v %v", j, err)
+	}
+
+	_, err = New(Config{Type: "file"}, s)
+	if err == nil {
+		t.Error("本地文件抓取记录没有配置路径时应返回错误")
+	}
+
+	_, err = New(Config{Type: "database"}, s)
+	if err == nil {
+		t.Error("未知的抓取记录类型应返回错误")
+	}
+
+	for _, config := range []Config{{Type: "file", Path: "journal.jsonl"}, {Type: "store"}} {
+		j, err = New(config, s)
+		if err != nil || j == nil {
+			t.Errorf("%s: %v", config.Type, err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package journal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nzai/stockrecorder/market"
+	"github.com/nzai/stockrecorder/store"
+)
+
+const (
+	// attachmentName 抓取记录在存储中的附件名
+	attachmentName = "journal.jsonl"
+)
+
+// Store 与当天数据一起保存在存储中的抓取记录，同一天同一时间只有一个实例抓取(见lease)，追加时不会互相覆盖
+type Store struct {
+	store store.Store
+}
+
+// NewStore 新建保存在存储中的抓取记录
+func NewStore(s store.Store) *Store {
+	return &Store{store: s}
+}
+
+// Append 追加一次抓取的记录
+func (s Store) Append(ctx context.Context, _market market.Market, run Run) error {
+
+	line, err := marshal(run)
+	if err != nil {
+		return err
+	}
+
+	date, err := s.date(_market, run.Date)
+	if err != nil {
+		return err
+	}
+
+	data, err := s.store.LoadAttachment(ctx, _market, date, attachmentName)
+	if err != nil && err != store.ErrAttachmentNotFound {
+		return err
+	}
+
+	return s.store.SaveAttachment(ctx, _market, date, attachmentName, append(data, line...))
+}
+
+// List 查询，需要指定市场及日期范围
+func (s Store) List(ctx context.Context, filter Filter) ([]Run, error) {
+
+	if len(filter.Markets) == 0 || filter.Start.IsZero() || filter.End.IsZero() {
+		return nil, fmt.Errorf("查询存储中的抓取记录需要指定市场及起止日期")
+	}
+
+	var matched []Run
+	for _, _market := range filter.Markets {
+
+		location, err := time.LoadLocation(_market.Timezone())
+		if err != nil {
+			return nil, err
+		}
+
+		start := time.Date(filter.Start.Year(), filter.Start.Month(), filter.Start.Day(), 0, 0, 0, 0, location)
+		end := time.Date(filter.End.Year(), filter.End.Month(), filter.End.Day(), 0, 0, 0, 0, location)
+		for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
+
+			data, err := s.store.LoadAttachment(ctx, _market, date, attachmentName)
+			if err == store.ErrAttachmentNotFound {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			runs, err := unmarshal(data)
+			if err != nil {
+				return nil, fmt.Errorf("[%s] %s: %v", _market.Name(), date.Format(datePattern), err)
+			}
+
+			for _, run := range runs {
+				if filter.Match(run) {
+					matched = append(matched, run)
+				}
+			}
+		}
+	}
+	sortRuns(matched)
+
+	return matched, nil
+}
+
+// date 按市场所在时区解析交易日
+func (s Store) date(_market market.Market, text string) (time.Time, error) {
+
+	location, err := time.LoadLocation(_market.Timezone())
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.ParseInLocation(datePattern, text, location)
+}
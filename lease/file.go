@@ -0,0 +1,79 @@
+package lease
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// File 基于文件锁(Windows为LockFileEx，其他系统为flock)的租约，只能协调同一台主机上的实例，持有者退出或崩溃时由系统释放
+type File struct {
+	dir string
+}
+
+// NewFile 新建基于文件锁的租约管理，锁文件保存在dir中
+func NewFile(dir string) *File {
+	return &File{dir: dir}
+}
+
+// Acquire 获取租约，ttl对文件锁没有意义
+func (f File) Acquire(ctx context.Context, key string, ttl time.Duration) (Lease, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	err := os.MkdirAll(f.dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(f.dir, strings.Replace(key, string(filepath.Separator), "_", -1)+".lock")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	// 已被其他实例持有时返回ErrHeld
+	err = lockFile(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// 记录持有者，便于排查
+	file.Truncate(0)
+	file.WriteAt([]byte(newOwner()+"\n"), 0)
+
+	return &fileLease{key: key, file: file}, nil
+}
+
+// fileLease 文件锁租约
+type fileLease struct {
+	key  string
+	file *os.File
+}
+
+// Key 租约的键
+func (l fileLease) Key() string {
+	return l.key
+}
+
+// Renew 文件锁由系统保持，不需要续约
+func (l fileLease) Renew(ctx context.Context) error {
+	return nil
+}
+
+// Release 释放
+func (l fileLease) Release(ctx context.Context) error {
+
+	err := unlockFile(l.file)
+	if err != nil {
+		l.file.Close()
+		return err
+	}
+
+	return l.file.Close()
+}
@@ -0,0 +1,148 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileLease(t *testing.T) {
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	locker := NewFile(filepath.Join(dir, "locks"))
+
+	lease, err := locker.Acquire(ctx, "america/20180102", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lease.Key() != "america/20180102" {
+		t.Errorf("租约的键为%s，应为america/20180102", lease.Key())
+	}
+
+	// 锁文件中记录了持有者，键中的路径分隔符被替换
+	content, err := ioutil.ReadFile(filepath.Join(dir, "locks", strings.Replace("america/20180102", string(filepath.Separator), "_", -1)+".lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host, _ := os.Hostname()
+	if !strings.HasPrefix(string(content), host+":") {
+		t.Errorf("锁文件中的持有者为%s，应以主机名开头", content)
+	}
+
+	// 已被持有时其他实例无法获取，其他键不受影响
+	_, err = NewFile(filepath.Join(dir, "locks")).Acquire(ctx, "america/20180102", time.Minute)
+	if err != ErrHeld {
+		t.Fatalf("租约已被持有时应返回ErrHeld: %v", err)
+	}
+
+	other, err := locker.Acquire(ctx, "america/20180103", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Release(ctx)
+
+	// 文件锁不需要续约
+	err = lease.Renew(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = lease.Release(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 释放后可以再次获取
+	lease, err = locker.Acquire(ctx, "america/20180102", time.Minute)
+	if err != nil {
+		t.Fatalf("释放后应能再次获取: %v", err)
+	}
+
+	err = lease.Release(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileLeaseCanceled(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := NewFile(t.TempDir()).Acquire(ctx, "america/20180102", time.Minute)
+	if err != context.Canceled {
+		t.Fatalf("已取消时应返回context.Canceled: %v", err)
+	}
+}
+
+// failingLease 续约次数达到limit后失败的租约
+type failingLease struct {
+	renewed *int
+	limit   int
+}
+
+// Key 租约的键
+func (l failingLease) Key() string {
+	return "failing"
+}
+
+// Renew 续约
+func (l failingLease) Renew(ctx context.Context) error {
+
+	*l.renewed++
+	if *l.renewed >= l.limit {
+		return ErrLost
+	}
+
+	return nil
+}
+
+// Release 释放
+func (l failingLease) Release(ctx context.Context) error {
+	return nil
+}
+
+func TestKeep(t *testing.T) {
+
+	var renewed int
+	lost := make(chan error, 1)
+
+	done := make(chan bool)
+	go func() {
+		Keep(context.Background(), failingLease{renewed: &renewed, limit: 3}, time.Millisecond*30, func(err error) { lost <- err })
+		close(done)
+	}()
+
+	// 续约失败时通知并停止续约
+	select {
+	case err := <-lost:
+		if !errors.Is(err, ErrLost) {
+			t.Errorf("续约失败的错误为%v，应为ErrLost", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("续约失败时没有通知")
+	}
+
+	<-done
+	if renewed != 3 {
+		t.Errorf("续约了%d次，应为3次", renewed)
+	}
+
+	// 取消后停止续约，不通知
+	ctx, cancel := context.WithCancel(context.Background())
+	renewed = 0
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		cancel()
+	}()
+
+	Keep(ctx, failingLease{renewed: &renewed, limit: 1000}, time.Millisecond*30, func(err error) { t.Errorf("取消后不应通知: %v", err) })
+}
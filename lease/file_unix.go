@@ -0,0 +1,24 @@
+//go:build !windows
+
+package lease
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile 以非阻塞方式加排他锁，已被其他实例持有时返回ErrHeld
+func lockFile(file *os.File) error {
+
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return ErrHeld
+	}
+
+	return err
+}
+
+// unlockFile 解锁
+func unlockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
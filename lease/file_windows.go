@@ -0,0 +1,52 @@
+//go:build windows
+
+package lease
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// lockfileFailImmediately 不等待
+	lockfileFailImmediately = 0x00000001
+	// lockfileExclusiveLock 排他锁
+	lockfileExclusiveLock = 0x00000002
+	// errorLockViolation 已被其他进程锁定
+	errorLockViolation syscall.Errno = 33
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+// lockFile 以非阻塞方式锁定文件的第一个字节，已被其他实例持有时返回ErrHeld
+func lockFile(file *os.File) error {
+
+	var overlapped syscall.Overlapped
+	r, _, err := procLockFileEx.Call(file.Fd(), lockfileExclusiveLock|lockfileFailImmediately, 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+	if r != 0 {
+		return nil
+	}
+
+	if err == errorLockViolation {
+		return ErrHeld
+	}
+
+	return err
+}
+
+// unlockFile 解锁
+func unlockFile(file *os.File) error {
+
+	var overlapped syscall.Overlapped
+	r, _, err := procUnlockFileEx.Call(file.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+	if r != 0 {
+		return nil
+	}
+
+	return err
+}
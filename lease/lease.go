@@ -0,0 +1,74 @@
+package lease
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+var (
+	// ErrHeld 租约已被其他实例持有
+	ErrHeld = errors.New("租约已被其他实例持有")
+	// ErrLost 租约已过期或被其他实例取得
+	ErrLost = errors.New("租约已丢失")
+)
+
+// Lease 租约
+type Lease interface {
+	// 租约的键
+	Key() string
+	// 续约
+	Renew(ctx context.Context) error
+	// 释放
+	Release(ctx context.Context) error
+}
+
+// Locker 租约管理，多个记录器实例通过同一个Locker分配工作
+type Locker interface {
+	// 获取租约，已被其他实例持有时返回ErrHeld
+	Acquire(ctx context.Context, key string, ttl time.Duration) (Lease, error)
+}
+
+// newOwner 租约持有者标识: 主机名:进程号:随机数
+func newOwner() string {
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	buffer := make([]byte, 8)
+	rand.Read(buffer)
+
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), hex.EncodeToString(buffer))
+}
+
+// Keep 每隔ttl/3续约一次，直到ctx取消；续约失败时调用lost并返回
+func Keep(ctx context.Context, lease Lease, ttl time.Duration, lost func(err error)) {
+
+	interval := ttl / 3
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := lease.Renew(ctx)
+		if err != nil && ctx.Err() == nil {
+			lost(err)
+			return
+		}
+	}
+}
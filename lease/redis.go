@@ -0,0 +1,87 @@
+package lease
+
+import (
+	"context"
+	"time"
+
+	"gopkg.in/redis.v5"
+)
+
+const (
+	// renewScript 只有持有者才能续约
+	renewScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+	// releaseScript 只有持有者才能释放
+	releaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+)
+
+// RedisConfig Redis租约配置
+type RedisConfig struct {
+	Options redis.Options `yaml:"options"` // 连接选项
+	Prefix  string        `yaml:"prefix"`  // 键前缀，默认为 lease:
+}
+
+// Redis 基于Redis的租约，SET NX PX获取，持有者崩溃后租约过期，其他实例可以接手
+type Redis struct {
+	client *redis.Client
+	prefix string
+	owner  string
+}
+
+// NewRedis 新建基于Redis的租约管理
+func NewRedis(config RedisConfig) *Redis {
+
+	prefix := config.Prefix
+	if prefix == "" {
+		prefix = "lease:"
+	}
+
+	return &Redis{client: redis.NewClient(&config.Options), prefix: prefix, owner: newOwner()}
+}
+
+// Acquire 获取租约
+func (r Redis) Acquire(ctx context.Context, key string, ttl time.Duration) (Lease, error) {
+
+	ok, err := r.client.WithContext(ctx).SetNX(r.prefix+key, r.owner, ttl).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, ErrHeld
+	}
+
+	return &redisLease{client: r.client, key: r.prefix + key, owner: r.owner, ttl: ttl}, nil
+}
+
+// redisLease Redis租约
+type redisLease struct {
+	client *redis.Client
+	key    string
+	owner  string
+	ttl    time.Duration
+}
+
+// Key 租约的键
+func (l redisLease) Key() string {
+	return l.key
+}
+
+// Renew 续约
+func (l redisLease) Renew(ctx context.Context) error {
+
+	result, err := l.client.WithContext(ctx).Eval(renewScript, []string{l.key}, l.owner, int64(l.ttl/time.Millisecond)).Result()
+	if err != nil {
+		return err
+	}
+
+	if n, ok := result.(int64); !ok || n == 0 {
+		return ErrLost
+	}
+
+	return nil
+}
+
+// Release 释放
+func (l redisLease) Release(ctx context.Context) error {
+	return l.client.WithContext(ctx).Eval(releaseScript, []string{l.key}, l.owner).Err()
+}
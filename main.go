@@ -1,9 +1,15 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
 	"runtime/debug"
+	"syscall"
 
+	"github.com/nzai/stockrecorder/journal"
 	"github.com/nzai/stockrecorder/market"
 	"github.com/nzai/stockrecorder/recorder"
 	"github.com/nzai/stockrecorder/source"
@@ -14,28 +20,126 @@ func main() {
 
 	defer func() {
 		// 捕获panic异常
-		log.Print("发生了致命错误")
 		if err := recover(); err != nil {
-			log.Print("致命错误:", err)
+			log.Print("发生了致命错误:", err)
+			debug.PrintStack()
 		}
-		debug.PrintStack()
 	}()
 
+	// 子命令
+	if len(os.Args) > 1 {
+		if cmd, found := commands[os.Args[1]]; found {
+			err := cmd.run(os.Args[2:])
+			if err != nil {
+				log.Fatalf("执行%s时发生错误: %v", os.Args[1], err)
+			}
+			return
+		}
+	}
+
+	// 没有子命令时持续记录，第一个参数为配置文件路径
+	var configPath string
+	if len(os.Args) > 1 {
+		configPath = os.Args[1]
+	}
+
+	err := run(configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run 持续记录各市场的数据
+func run(configPath string) error {
+
 	//	读取配置文件
-	config, err := parseConfig()
+	config, err := parseConfig(configPath)
 	if err != nil {
-		log.Fatal("读取配置文件错误: ", err)
+		return fmt.Errorf("读取配置文件错误: %v", err)
 	}
 
 	log.Print("启动市场监视任务")
 
-	// 创建记录器，使用雅虎财经作为数据源，阿里云OSS作为存储，监控美股、A股、港股
+	// 运行的市场，默认美股、A股、港股
+	markets, err := config.markets()
+	if err != nil {
+		return err
+	}
+
+	// 创建记录器，使用雅虎财经(query2、query1两个接口域名互为备份)作为数据源，阿里云OSS作为存储
+	r, err := newRecorder(config, markets...)
+	if err != nil {
+		return err
+	}
+	ctx := signalContext()
+	serveHTTP(ctx, config.HTTP, r)
+	r.RunAndWait(ctx)
+
+	log.Print("市场监视任务已停止")
+
+	return nil
+}
+
+// newRecorder 按配置创建记录器
+func newRecorder(config *Config, markets ...market.Market) (*recorder.Recorder, error) {
+
+	// 按配置的优先顺序组合数据源
+	symbols := config.yahooSymbols()
+	chain, err := source.NewChain(config.Sources,
+		source.NewYahooFinance(config.Limits["yahoo"], symbols),                                                        // 雅虎财经
+		source.NewYahooFinanceHost("yahoo-query1", "query1.finance.yahoo.com", config.Limits["yahoo-query1"], symbols), // 雅虎财经备用接口
+	)
+	if err != nil {
+		return nil, fmt.Errorf("数据源配置错误: %v", err)
+	}
+
+	// 多实例时按市场和日期分配工作
+	locker, err := config.Lease.locker()
+	if err != nil {
+		return nil, fmt.Errorf("租约配置错误: %v", err)
+	}
+
+	// 阿里云OSS作为存储
+	s := store.NewAliyunOSS(config.Aliyun.OSS)
+
+	// 每次抓取的记录
+	j, err := journal.New(config.Journal, s)
+	if err != nil {
+		return nil, fmt.Errorf("抓取记录配置错误: %v", err)
+	}
+
 	r := recorder.NewRecorder(
-		source.NewYahooFinance(),              // 雅虎财经作为数据源
-		store.NewAliyunOSS(config.Aliyun.OSS), // 阿里云OSS作为存储
-		market.America{},                      // 美股
-		market.China{},                        // A股
-		market.HongKong{},                     // 港股
+		config.Recorder, // 记录器配置
+		chain,           // 数据源
+		s,               // 存储
+		markets...,
 	)
-	r.RunAndWait()
+	r.SetLocker(locker)
+	r.SetJournal(j)
+
+	return r, nil
+}
+
+// signalContext 收到退出信号时取消的context
+func signalContext() context.Context {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go waitForSignal(cancel)
+
+	return ctx
+}
+
+// waitForSignal 第一次收到SIGINT/SIGTERM时取消，等待进行中的任务结束；第二次收到时强制退出
+func waitForSignal(cancel context.CancelFunc) {
+
+	ch := make(chan os.Signal, 2)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+
+	sig := <-ch
+	log.Printf("收到%v信号，停止定时任务并等待进行中的任务结束，再次发送信号将强制退出", sig)
+	cancel()
+
+	sig = <-ch
+	log.Printf("再次收到%v信号，强制退出", sig)
+	os.Exit(1)
 }
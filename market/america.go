@@ -5,13 +5,19 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/nzai/go-utility/net"
+	"github.com/nzai/stockrecorder/calendar"
 )
 
 // America 美国证券市场
 type America struct{}
 
+func init() {
+	Register(America{})
+}
+
 // Name 名称
 func (m America) Name() string {
 	return "America"
@@ -22,17 +28,25 @@ func (m America) Timezone() string {
 	return "America/New_York"
 }
 
+// Calendar 交易日历
+func (m America) Calendar() *calendar.Calendar {
+	return calendar.Get(m.Name())
+}
+
+// Sessions 交易时段
+func (m America) Sessions(date time.Time) []calendar.Session {
+	return m.Calendar().Sessions(date)
+}
+
 // Companies 上市公司
 func (m America) Companies() ([]Company, error) {
 
-	urls := [...]string{
-		"http://www.nasdaq.com/screening/companies-by-industry.aspx?exchange=NASDAQ&render=download",
-		"http://www.nasdaq.com/screening/companies-by-industry.aspx?exchange=NYSE&render=download",
-		"http://www.nasdaq.com/screening/companies-by-industry.aspx?exchange=AMEX&render=download",
-	}
+	exchanges := [...]string{"NASDAQ", "NYSE", "AMEX"}
 
 	var list []Company
-	for _, url := range urls {
+	for _, exchange := range exchanges {
+
+		url := "http://www.nasdaq.com/screening/companies-by-industry.aspx?exchange=" + exchange + "&render=download"
 
 		//	尝试从网络获取实时上市公司列表
 		csv, err := net.DownloadStringRetry(url, retryTimes, retryIntervalSeconds)
@@ -41,7 +55,7 @@ func (m America) Companies() ([]Company, error) {
 		}
 
 		//	解析CSV
-		companies, err := m.parseCSV(csv)
+		companies, err := m.parseCSV(csv, exchange)
 		if err != nil {
 			return nil, err
 		}
@@ -55,8 +69,8 @@ func (m America) Companies() ([]Company, error) {
 	return list, nil
 }
 
-//	解析CSV
-func (m America) parseCSV(content string) ([]Company, error) {
+//	解析CSV，列依次为Symbol、Name、LastSale、MarketCap、IPOyear、Sector、industry
+func (m America) parseCSV(content, exchange string) ([]Company, error) {
 
 	reader := csv.NewReader(strings.NewReader(content))
 	records, err := reader.ReadAll()
@@ -81,15 +95,32 @@ func (m America) parseCSV(content string) ([]Company, error) {
 		}
 		dict[parts[0]] = true
 
-		companies = append(companies, Company{
-			Code: strings.Trim(parts[0], " "),
-			Name: strings.Trim(parts[1], " ")})
+		company := Company{
+			Code:     strings.Trim(parts[0], " "),
+			Name:     strings.Trim(parts[1], " "),
+			Exchange: exchange,
+			Type:     TypeStock,
+			Currency: "USD",
+		}
+
+		if len(parts) > 6 {
+			company.Sector = m.available(parts[5])
+			company.Industry = m.available(parts[6])
+		}
+
+		companies = append(companies, company)
 	}
 
 	return companies, nil
 }
 
-// YahooQueryCode 雅虎查询代码
-func (m America) YahooQueryCode(company Company) string {
-	return company.Code
+// available 去掉CSV中表示没有数据的n/a
+func (m America) available(value string) string {
+
+	value = strings.Trim(value, " ")
+	if strings.EqualFold(value, "n/a") {
+		return ""
+	}
+
+	return value
 }
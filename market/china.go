@@ -4,14 +4,21 @@ import (
 	"fmt"
 	"regexp"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/guotie/gogb2312"
 	"github.com/nzai/go-utility/net"
+	"github.com/nzai/stockrecorder/calendar"
 )
 
 // China 中国证券市场
 type China struct{}
 
+func init() {
+	Register(China{})
+}
+
 // Name 名称
 func (m China) Name() string {
 	return "China"
@@ -22,6 +29,16 @@ func (m China) Timezone() string {
 	return "Asia/Shanghai"
 }
 
+// Calendar 交易日历
+func (m China) Calendar() *calendar.Calendar {
+	return calendar.Get(m.Name())
+}
+
+// Sessions 交易时段
+func (m China) Sessions(date time.Time) []calendar.Session {
+	return m.Calendar().Sessions(date)
+}
+
 // Companies 上市公司
 func (m China) Companies() ([]Company, error) {
 
@@ -111,7 +128,7 @@ func (m China) parseShanghaiJSON(text string) ([]Company, error) {
 
 	var companies []Company
 	for _, section := range group {
-		companies = append(companies, Company{Code: section[1], Name: section[2]})
+		companies = append(companies, m.company(section[1], section[2], "SSE"))
 	}
 
 	if len(companies) == 0 {
@@ -162,7 +179,7 @@ func (m China) parseShenzhenHTML(html string) ([]Company, error) {
 
 	var companies []Company
 	for _, section := range group {
-		companies = append(companies, Company{Code: section[1], Name: section[2]})
+		companies = append(companies, m.company(section[1], section[2], "SZSE"))
 	}
 
 	if len(companies) == 0 {
@@ -172,24 +189,24 @@ func (m China) parseShenzhenHTML(html string) ([]Company, error) {
 	return companies, nil
 }
 
-// YahooQueryCode 雅虎查询代码
-func (m China) YahooQueryCode(company Company) string {
-
-	var suffix string
-	switch company.Code[:1] {
-	case "0":
-		suffix = "SZ"
-	case "2":
-		suffix = "SZ"
-	case "3":
-		suffix = "SZ"
-	case "9":
-		suffix = "SS"
-	case "6":
-		suffix = "SS"
-	default:
-		suffix = "SS"
-	}
-
-	return company.Code + "." + suffix
+// company 上市公司，A股以人民币交易，沪市B股(900)以美元、深市B股(200)以港币交易，每手100股
+func (m China) company(code, name, exchange string) Company {
+
+	company := Company{
+		Code:     code,
+		Name:     name,
+		Exchange: exchange,
+		Type:     TypeStock,
+		Currency: "CNY",
+		LotSize:  100,
+	}
+
+	switch {
+	case strings.HasPrefix(code, "900"):
+		company.Currency = "USD"
+	case strings.HasPrefix(code, "200"):
+		company.Currency = "HKD"
+	}
+
+	return company
 }
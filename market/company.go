@@ -6,13 +6,74 @@ import (
 	"strings"
 )
 
+const (
+	// companyVersionBasic 只有名称、代码的旧格式
+	companyVersionBasic = 0
+	// companyVersionMetadata 带交易所、证券类型等信息的格式
+	companyVersionMetadata = 1
+)
+
+const (
+	// TypeStock 股票
+	TypeStock = "stock"
+	// TypeETP 交易所买卖产品(ETF等)
+	TypeETP = "etp"
+	// TypeWarrant 衍生权证
+	TypeWarrant = "warrant"
+	// TypeCBBC 牛熊证
+	TypeCBBC = "cbbc"
+	// TypeREIT 房地产投资信托基金
+	TypeREIT = "reit"
+	// TypeBond 债务证券
+	TypeBond = "bond"
+)
+
 // Company 公司
 type Company struct {
-	Name string // 名称
-	Code string // 代码
+	Name     string // 名称
+	Code     string // 代码
+	Exchange string // 交易所，如 NASDAQ、SSE
+	Type     string // 证券类型，如 stock、etp、warrant
+	Currency string // 交易币种，如 USD
+	Sector   string // 板块
+	Industry string // 行业
+	LotSize  int    // 每手股数，0为未知
 }
 
-// Marshal 序列化
+// fill 用other补充为空的字段(代码除外)
+func (c Company) fill(other Company) Company {
+
+	if c.Name == "" {
+		c.Name = other.Name
+	}
+	if c.Exchange == "" {
+		c.Exchange = other.Exchange
+	}
+	if c.Type == "" {
+		c.Type = other.Type
+	}
+	if c.Currency == "" {
+		c.Currency = other.Currency
+	}
+	if c.Sector == "" {
+		c.Sector = other.Sector
+	}
+	if c.Industry == "" {
+		c.Industry = other.Industry
+	}
+	if c.LotSize == 0 {
+		c.LotSize = other.LotSize
+	}
+
+	return c
+}
+
+// hasMetadata 是否有名称、代码以外的信息
+func (c Company) hasMetadata() bool {
+	return c.Exchange != "" || c.Type != "" || c.Currency != "" || c.Sector != "" || c.Industry != "" || c.LotSize != 0
+}
+
+// Marshal 序列化，名称后的一个字节为格式版本，没有其他信息时与旧格式相同
 func (c Company) Marshal() []byte {
 
 	name := []byte(c.Name)
@@ -22,19 +83,61 @@ func (c Company) Marshal() []byte {
 	copy(buffer[:16], []byte(c.Code))
 	binary.BigEndian.PutUint16(buffer[16:18], uint16(nameLen))
 	copy(buffer[18:18+nameLen], name)
-	buffer[18+nameLen] = 0
+	buffer[18+nameLen] = companyVersionBasic
 
-	return buffer
+	if !c.hasMetadata() {
+		return buffer
+	}
+
+	buffer[18+nameLen] = companyVersionMetadata
+	for _, field := range []string{c.Exchange, c.Type, c.Currency, c.Sector, c.Industry} {
+		buffer = appendString(buffer, field)
+	}
+
+	lotSize := make([]byte, 4)
+	binary.BigEndian.PutUint32(lotSize, uint32(c.LotSize))
+
+	return append(buffer, lotSize...)
 }
 
-// Unmarshal 反序列化
+// Unmarshal 反序列化，返回读取的字节数
 func (c *Company) Unmarshal(buffer []byte) int {
 
-	c.Code = strings.Trim(string(buffer[:16]), string(0x0))
+	c.Code = strings.Trim(string(buffer[:16]), "\x00")
 	nameLen := int(binary.BigEndian.Uint16(buffer[16:18]))
-	c.Name = strings.Trim(string(buffer[18:18+nameLen]), string(0x0))
+	c.Name = strings.Trim(string(buffer[18:18+nameLen]), "\x00")
+
+	offset := 19 + nameLen
+	if buffer[18+nameLen] != companyVersionMetadata {
+		return offset
+	}
 
-	return 19 + nameLen
+	for _, field := range []*string{&c.Exchange, &c.Type, &c.Currency, &c.Sector, &c.Industry} {
+		*field, offset = readString(buffer, offset)
+	}
+
+	c.LotSize = int(binary.BigEndian.Uint32(buffer[offset : offset+4]))
+
+	return offset + 4
+}
+
+// appendString 追加长度(2字节)及内容
+func appendString(buffer []byte, text string) []byte {
+
+	size := make([]byte, 2)
+	binary.BigEndian.PutUint16(size, uint16(len(text)))
+
+	buffer = append(buffer, size...)
+	return append(buffer, text...)
+}
+
+// readString 读取长度(2字节)及内容，返回内容及之后的位置
+func readString(buffer []byte, offset int) (string, int) {
+
+	size := int(binary.BigEndian.Uint16(buffer[offset : offset+2]))
+	offset += 2
+
+	return string(buffer[offset : offset+size]), offset + size
 }
 
 // Equal 是否相同
@@ -48,6 +151,10 @@ func (c Company) Equal(s Company) error {
 		return fmt.Errorf("Company Name不相等:c.Name=[%s] s.Name=[%s]", c.Name, s.Name)
 	}
 
+	if c.Exchange != s.Exchange || c.Type != s.Type || c.Currency != s.Currency || c.Sector != s.Sector || c.Industry != s.Industry || c.LotSize != s.LotSize {
+		return fmt.Errorf("Company %s的信息不相等:c=[%+v] s=[%+v]", c.Code, c, s)
+	}
+
 	return nil
 }
 
@@ -63,3 +170,29 @@ func (l CompanyList) Swap(i, j int) {
 func (l CompanyList) Less(i, j int) bool {
 	return l[i].Code < l[j].Code
 }
+
+// Marshal 序列化
+func (l CompanyList) Marshal() []byte {
+
+	buffer := make([]byte, 4)
+	binary.BigEndian.PutUint32(buffer, uint32(len(l)))
+	for _, company := range l {
+		buffer = append(buffer, company.Marshal()...)
+	}
+
+	return buffer
+}
+
+// Unmarshal 反序列化
+func (l *CompanyList) Unmarshal(buffer []byte) {
+
+	count := int(binary.BigEndian.Uint32(buffer[:4]))
+	offset := 4
+	for index := 0; index < count; index++ {
+
+		company := Company{}
+		offset += company.Unmarshal(buffer[offset:])
+
+		*l = append(*l, company)
+	}
+}
@@ -0,0 +1,84 @@
+package market
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// oldCompany 只有名称、代码的旧格式(版本0)
+func oldCompany(code, name string) []byte {
+
+	buffer := make([]byte, 19+len(name))
+	copy(buffer[:16], code)
+	binary.BigEndian.PutUint16(buffer[16:18], uint16(len(name)))
+	copy(buffer[18:], name)
+
+	return buffer
+}
+
+func TestCompanyBasicFormat(t *testing.T) {
+
+	old := oldCompany("AAPL", "Apple Inc.")
+
+	// 没有其他信息时与旧格式相同
+	company := Company{Code: "AAPL", Name: "Apple Inc."}
+	if !bytes.Equal(company.Marshal(), old) {
+		t.Fatalf("没有其他信息的公司序列化后与旧格式不同: %v", company.Marshal())
+	}
+
+	// 旧格式后面紧跟其他数据时只读取公司本身
+	var read Company
+	size := read.Unmarshal(append(old, testSeries(1514903400, 2).Marshal()...))
+	if size != len(old) {
+		t.Fatalf("读取了%d字节，应为%d字节", size, len(old))
+	}
+
+	err := read.Equal(company)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompanyMetadataFormat(t *testing.T) {
+
+	company := Company{
+		Code:     "0700",
+		Name:     "騰訊控股",
+		Exchange: "HKEX",
+		Type:     "stock",
+		Currency: "HKD",
+		Sector:   "資訊科技業",
+		Industry: "軟件服務",
+		LotSize:  100,
+	}
+
+	buffer := company.Marshal()
+
+	var read Company
+	size := read.Unmarshal(buffer)
+	if size != len(buffer) {
+		t.Fatalf("读取了%d字节，应为%d字节", size, len(buffer))
+	}
+
+	err := read.Equal(company)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 列表中新旧格式混合
+	list := CompanyList{{Code: "0005", Name: "匯豐控股"}, company, {Code: "0941", LotSize: 500}}
+
+	var readList CompanyList
+	readList.Unmarshal(list.Marshal())
+	if len(readList) != len(list) {
+		t.Fatalf("读取了%d家公司，应为%d家", len(readList), len(list))
+	}
+
+	for index := range list {
+		err = readList[index].Equal(list[index])
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
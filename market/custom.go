@@ -0,0 +1,73 @@
+package market
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/nzai/stockrecorder/calendar"
+)
+
+// CustomConfig 自定义市场配置
+type CustomConfig struct {
+	Name      string    `yaml:"name"`      // 名称，同时用于交易日历、定时任务等按市场的配置
+	Timezone  string    `yaml:"timezone"`  // 所处时区，如 Asia/Tokyo
+	Suffix    string    `yaml:"suffix"`    // 雅虎财经查询代码的后缀，如 .T，同 symbols.yahoo.suffixes
+	Companies []Company `yaml:"companies"` // 上市公司
+}
+
+// Custom 按配置定义的市场，上市公司为配置中的固定列表
+type Custom struct {
+	config CustomConfig
+}
+
+// NewCustom 新建自定义市场
+func NewCustom(config CustomConfig) (*Custom, error) {
+
+	if config.Name == "" {
+		return nil, fmt.Errorf("自定义市场缺少名称")
+	}
+
+	_, err := time.LoadLocation(config.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] 错误的时区%s: %v", config.Name, config.Timezone, err)
+	}
+
+	if len(config.Companies) == 0 {
+		return nil, fmt.Errorf("[%s] 自定义市场没有上市公司", config.Name)
+	}
+
+	return &Custom{config: config}, nil
+}
+
+// Name 名称
+func (m Custom) Name() string {
+	return m.config.Name
+}
+
+// Timezone 所处时区
+func (m Custom) Timezone() string {
+	return m.config.Timezone
+}
+
+// Calendar 交易日历，没有配置时只按周六、周日休市
+func (m Custom) Calendar() *calendar.Calendar {
+	return calendar.Get(m.Name())
+}
+
+// Sessions 交易时段
+func (m Custom) Sessions(date time.Time) []calendar.Session {
+	return m.Calendar().Sessions(date)
+}
+
+// Companies 上市公司
+func (m Custom) Companies() ([]Company, error) {
+
+	companies := make([]Company, len(m.config.Companies))
+	copy(companies, m.config.Companies)
+
+	//	按Code排序
+	sort.Sort(CompanyList(companies))
+
+	return companies, nil
+}
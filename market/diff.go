@@ -0,0 +1,72 @@
+package market
+
+import (
+	"fmt"
+	"sort"
+)
+
+// CompanyRename 公司更名
+type CompanyRename struct {
+	Code    string // 代码
+	OldName string // 原名称
+	NewName string // 新名称
+}
+
+// CompanyDiff 两份上市公司列表的差异
+type CompanyDiff struct {
+	Added   []Company       // 新上市
+	Removed []Company       // 已退市
+	Renamed []CompanyRename // 更名
+}
+
+// DiffCompanies 比较两份上市公司列表，from为较早的列表，结果按代码排序
+func DiffCompanies(from, to []Company) CompanyDiff {
+
+	before := make(map[string]Company, len(from))
+	for _, company := range from {
+		before[company.Code] = company
+	}
+
+	after := make(map[string]Company, len(to))
+	for _, company := range to {
+		after[company.Code] = company
+	}
+
+	var diff CompanyDiff
+	for code, company := range after {
+
+		old, found := before[code]
+		if !found {
+			diff.Added = append(diff.Added, company)
+			continue
+		}
+
+		if old.Name != company.Name {
+			diff.Renamed = append(diff.Renamed, CompanyRename{Code: code, OldName: old.Name, NewName: company.Name})
+		}
+	}
+
+	for code, company := range before {
+		if _, found := after[code]; !found {
+			diff.Removed = append(diff.Removed, company)
+		}
+	}
+
+	sort.Sort(CompanyList(diff.Added))
+	sort.Sort(CompanyList(diff.Removed))
+	sort.Slice(diff.Renamed, func(i, j int) bool {
+		return diff.Renamed[i].Code < diff.Renamed[j].Code
+	})
+
+	return diff
+}
+
+// Empty 是否没有差异
+func (d CompanyDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Renamed) == 0
+}
+
+// String 摘要
+func (d CompanyDiff) String() string {
+	return fmt.Sprintf("新上市%d家，退市%d家，更名%d家", len(d.Added), len(d.Removed), len(d.Renamed))
+}
@@ -5,9 +5,11 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"errors"
 	"github.com/nzai/go-utility/net"
+	"github.com/nzai/stockrecorder/calendar"
 	"regexp"
 	"strconv"
 )
@@ -15,6 +17,10 @@ import (
 // England 英国证券市场
 type England struct{}
 
+func init() {
+	Register(England{})
+}
+
 // Name 名称
 func (m England) Name() string {
 	return "England"
@@ -25,6 +31,16 @@ func (m England) Timezone() string {
 	return "Europe/London"
 }
 
+// Calendar 交易日历
+func (m England) Calendar() *calendar.Calendar {
+	return calendar.Get(m.Name())
+}
+
+// Sessions 交易时段
+func (m England) Sessions(date time.Time) []calendar.Session {
+	return m.Calendar().Sessions(date)
+}
+
 // Companies 上市公司
 func (m England) Companies() ([]Company, error) {
 
@@ -50,34 +66,46 @@ func (m England) Companies() ([]Company, error) {
 	groups := regexCompany.FindAllStringSubmatch(body, -1)
 	pageSize := len(groups)
 
+	// 限流，最多同时抓取32页
 	ch := make(chan bool, 32)
-	defer close(ch)
 
-	wg := &sync.WaitGroup{}
-	wg.Add(totalPages)
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var firstErr error
 
 	companies := make([]Company, 0, totalPages*pageSize)
 	for index := 1; index <= totalPages; index++ {
 
 		ch <- true
-		go func(_index int, _wg *sync.WaitGroup) {
+		wg.Add(1)
+		go func(_index int) {
+			defer wg.Done()
+			defer func() { <-ch }()
 
 			_companies, err := m.queryCompanies(fmt.Sprintf("%s?page=%d", url, _index))
-			if err == nil {
-				companies = append(companies, _companies...)
-			}
 
-			<-ch
-			_wg.Done()
-		}(index, wg)
+			mutex.Lock()
+			defer mutex.Unlock()
 
-		// 限流
-		ch <- false
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("获取第%d页上市公司时发生错误: %v", _index, err)
+				}
+				return
+			}
+
+			companies = append(companies, _companies...)
+		}(index)
 	}
 
 	//	阻塞，直到抓取所有
 	wg.Wait()
 
+	// 缺少部分页面时列表不完整，返回错误
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
 	//	按Code排序
 	sort.Sort(CompanyList(companies))
 
@@ -100,14 +128,11 @@ func (m England) queryCompanies(url string) ([]Company, error) {
 	for _, group := range groups {
 
 		companies = append(companies, Company{
-			Code: strings.Trim(group[1], " "),
-			Name: strings.Trim(group[2], " ")})
+			Code:     strings.Trim(group[1], " "),
+			Name:     strings.Trim(group[2], " "),
+			Exchange: "LSE",
+			Type:     TypeStock})
 	}
 
 	return companies, nil
 }
-
-// YahooQueryCode 雅虎查询代码
-func (m England) YahooQueryCode(company Company) string {
-	return company.Code + ".L"
-}
@@ -0,0 +1,108 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// EventsName 公司事件保存到存储时的附件名
+	EventsName = "events.json"
+
+	// EventDividend 分红
+	EventDividend = "dividend"
+	// EventSplit 拆股(合股)
+	EventSplit = "split"
+	// EventEarnings 财报
+	EventEarnings = "earnings"
+)
+
+// Event 公司事件，分红、拆股为除权除息日，财报为发布日
+type Event struct {
+	Code        string  `json:"code"`                  // 公司代码
+	Type        string  `json:"type"`                  // 类型: dividend、split、earnings
+	Timestamp   int64   `json:"timestamp"`             // 时间戳
+	Amount      float64 `json:"amount,omitempty"`      // 每股分红
+	Numerator   float64 `json:"numerator,omitempty"`   // 拆股后的股数，如4:1拆股为4
+	Denominator float64 `json:"denominator,omitempty"` // 拆股前的股数，如4:1拆股为1
+}
+
+// Time 事件时间
+func (e Event) Time() time.Time {
+	return time.Unix(e.Timestamp, 0)
+}
+
+// Ratio 拆股比例(拆股后/拆股前)，不是拆股或比例错误时为1
+func (e Event) Ratio() float64 {
+
+	if e.Type != EventSplit || e.Numerator <= 0 || e.Denominator <= 0 {
+		return 1
+	}
+
+	return e.Numerator / e.Denominator
+}
+
+// String 描述
+func (e Event) String() string {
+
+	date := e.Time().Format("2006-01-02")
+	switch e.Type {
+	case EventDividend:
+		return fmt.Sprintf("%s\t%s\t分红\t每股%g", e.Code, date, e.Amount)
+	case EventSplit:
+		return fmt.Sprintf("%s\t%s\t拆股\t%g:%g", e.Code, date, e.Numerator, e.Denominator)
+	case EventEarnings:
+		return fmt.Sprintf("%s\t%s\t财报", e.Code, date)
+	default:
+		return fmt.Sprintf("%s\t%s\t%s", e.Code, date, e.Type)
+	}
+}
+
+// EventList 公司事件列表
+type EventList []Event
+
+// Sort 按代码、时间、类型排序
+func (l EventList) Sort() {
+	sort.SliceStable(l, func(i, j int) bool {
+		if l[i].Code != l[j].Code {
+			return l[i].Code < l[j].Code
+		}
+		if l[i].Timestamp != l[j].Timestamp {
+			return l[i].Timestamp < l[j].Timestamp
+		}
+		return l[i].Type < l[j].Type
+	})
+}
+
+// Marshal 序列化为json
+func (l EventList) Marshal() ([]byte, error) {
+	return json.MarshalIndent(l, "", "  ")
+}
+
+// Unmarshal 从json反序列化
+func (l *EventList) Unmarshal(buffer []byte) error {
+	return json.Unmarshal(buffer, l)
+}
+
+// Merge 合并事件，events中出现的公司替换l中该公司原有的事件，结果按代码、时间排序
+func (l EventList) Merge(events EventList) EventList {
+
+	replaced := make(map[string]bool, len(events))
+	for _, event := range events {
+		replaced[event.Code] = true
+	}
+
+	merged := make(EventList, 0, len(l)+len(events))
+	for _, event := range l {
+		if !replaced[event.Code] {
+			merged = append(merged, event)
+		}
+	}
+
+	merged = append(merged, events...)
+	merged.Sort()
+
+	return merged
+}
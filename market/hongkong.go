@@ -8,11 +8,16 @@ import (
 	"time"
 
 	"github.com/nzai/go-utility/net"
+	"github.com/nzai/stockrecorder/calendar"
 )
 
 // HongKong 香港证券市场
 type HongKong struct{}
 
+func init() {
+	Register(HongKong{})
+}
+
 // Name 名称
 func (m HongKong) Name() string {
 	return "HongKong"
@@ -23,21 +28,34 @@ func (m HongKong) Timezone() string {
 	return "Asia/Hong_Kong"
 }
 
+// Calendar 交易日历
+func (m HongKong) Calendar() *calendar.Calendar {
+	return calendar.Get(m.Name())
+}
+
+// Sessions 交易时段
+func (m HongKong) Sessions(date time.Time) []calendar.Session {
+	return m.Calendar().Sessions(date)
+}
+
 // Companies 上市公司
 func (m HongKong) Companies() ([]Company, error) {
 
-	source := map[string]string{
-		"http://www.hkex.com.hk/Market-Data/Securities-Prices/Equities?sc_lang=zh-HK":                      "https://www1.hkex.com.hk/hkexwidget/data/getequityfilter?lang=chi&token=%s&sort=5&order=0&all=1&qid=%d&callback=3322", // 股本證券
-		"http://www.hkex.com.hk/Market-Data/Securities-Prices/Exchange-Traded-Products?sc_lang=zh-hk":      "https://www1.hkex.com.hk/hkexwidget/data/getetpfilter?lang=chi&token=%s&sort=2&order=1&all=1&qid=%d&callback=3322",    // 交易所買賣產品
-		"http://www.hkex.com.hk/Market-Data/Securities-Prices/Derivative-Warrants?sc_lang=zh-hk":           "https://www1.hkex.com.hk/hkexwidget/data/getdwfilter?lang=chi&token=%s&sort=5&order=0&all=1&qid=%d&callback=3322",     // 衍生權證
-		"http://www.hkex.com.hk/Market-Data/Securities-Prices/Callable-Bull-Bear-Contracts?sc_lang=zh-hk":  "https://www1.hkex.com.hk/hkexwidget/data/getcbbcfilter?lang=chi&token=%s&sort=5&order=0&all=1&qid=%d&callback=3322",   // 牛熊證
-		"http://www.hkex.com.hk/Market-Data/Securities-Prices/Real-Estate-Investment-Trusts?sc_lang=zh-hk": "https://www1.hkex.com.hk/hkexwidget/data/getreitfilter?lang=chi&token=%s&sort=5&order=0&all=1&qid=%d&callback=3322",   // 房地產投資信託基金
-		"http://www.hkex.com.hk/Market-Data/Securities-Prices/Debt-Securities?sc_lang=zh-hk":               "https://www1.hkex.com.hk/hkexwidget/data/getdebtfilter?lang=chi&token=%s&sort=0&order=1&all=1&qid=%d&callback=3322",   // 債務證券
+	source := map[string]struct {
+		api   string
+		_type string
+	}{
+		"http://www.hkex.com.hk/Market-Data/Securities-Prices/Equities?sc_lang=zh-HK":                      {"https://www1.hkex.com.hk/hkexwidget/data/getequityfilter?lang=chi&token=%s&sort=5&order=0&all=1&qid=%d&callback=3322", TypeStock}, // 股本證券
+		"http://www.hkex.com.hk/Market-Data/Securities-Prices/Exchange-Traded-Products?sc_lang=zh-hk":      {"https://www1.hkex.com.hk/hkexwidget/data/getetpfilter?lang=chi&token=%s&sort=2&order=1&all=1&qid=%d&callback=3322", TypeETP},      // 交易所買賣產品
+		"http://www.hkex.com.hk/Market-Data/Securities-Prices/Derivative-Warrants?sc_lang=zh-hk":           {"https://www1.hkex.com.hk/hkexwidget/data/getdwfilter?lang=chi&token=%s&sort=5&order=0&all=1&qid=%d&callback=3322", TypeWarrant},   // 衍生權證
+		"http://www.hkex.com.hk/Market-Data/Securities-Prices/Callable-Bull-Bear-Contracts?sc_lang=zh-hk":  {"https://www1.hkex.com.hk/hkexwidget/data/getcbbcfilter?lang=chi&token=%s&sort=5&order=0&all=1&qid=%d&callback=3322", TypeCBBC},    // 牛熊證
+		"http://www.hkex.com.hk/Market-Data/Securities-Prices/Real-Estate-Investment-Trusts?sc_lang=zh-hk": {"https://www1.hkex.com.hk/hkexwidget/data/getreitfilter?lang=chi&token=%s&sort=5&order=0&all=1&qid=%d&callback=3322", TypeREIT},    // 房地產投資信託基金
+		"http://www.hkex.com.hk/Market-Data/Securities-Prices/Debt-Securities?sc_lang=zh-hk":               {"https://www1.hkex.com.hk/hkexwidget/data/getdebtfilter?lang=chi&token=%s&sort=0&order=1&all=1&qid=%d&callback=3322", TypeBond},    // 債務證券
 	}
 
 	var companies []Company
-	for page, api := range source {
-		_companies, err := m.queryCompanies(page, api)
+	for page, list := range source {
+		_companies, err := m.queryCompanies(page, list.api, list._type)
 		if err != nil {
 			return nil, err
 		}
@@ -52,7 +70,7 @@ func (m HongKong) Companies() ([]Company, error) {
 }
 
 //	解析香港证券交易所上市公司
-func (m HongKong) queryCompanies(page, api string) ([]Company, error) {
+func (m HongKong) queryCompanies(page, api, _type string) ([]Company, error) {
 
 	body, err := net.DownloadStringRetry(page, retryTimes, retryIntervalSeconds)
 	if err != nil {
@@ -76,14 +94,8 @@ func (m HongKong) queryCompanies(page, api string) ([]Company, error) {
 
 	var companies []Company
 	for _, section := range group {
-		companies = append(companies, Company{Code: section[1], Name: section[2]})
+		companies = append(companies, Company{Code: section[1], Name: section[2], Exchange: "HKEX", Type: _type, Currency: "HKD"})
 	}
 
 	return companies, nil
 }
-
-// YahooQueryCode 雅虎查询代码
-func (m HongKong) YahooQueryCode(company Company) string {
-
-	return company.Code + ".HK"
-}
@@ -2,7 +2,9 @@ package market
 
 import (
 	"errors"
-	"strings"
+	"time"
+
+	"github.com/nzai/stockrecorder/calendar"
 )
 
 const (
@@ -18,27 +20,13 @@ type Market interface {
 	Timezone() string
 	//	获取上市公司列表
 	Companies() ([]Company, error)
-
-	// 用于雅虎财经接口的查询代码后缀
-	YahooQueryCode(company Company) string
+	//	交易日历
+	Calendar() *calendar.Calendar
+	//	某天的交易时段(如午休前后的两个盘中时段)，非交易日返回nil
+	Sessions(date time.Time) []calendar.Session
 }
 
 var (
 	// ErrUnknownMarket 未知的市场
 	ErrUnknownMarket = errors.New("未知的市场")
 )
-
-// Get 获取市场
-func Get(name string) (Market, error) {
-
-	markets := []Market{America{}, China{}, HongKong{}}
-	for _, market := range markets {
-		if strings.ToLower(market.Name()) != strings.ToLower(name) {
-			continue
-		}
-
-		return market, nil
-	}
-
-	return nil, ErrUnknownMarket
-}
@@ -121,9 +121,12 @@ func (q *DailyQuote) FromQuote(_market Market, date time.Time, quotes []Quote) {
 // CompanyDailyQuote 公司每日报价
 type CompanyDailyQuote struct {
 	Company
-	Pre     QuoteSeries
-	Regular QuoteSeries
-	Post    QuoteSeries
+	Pre      QuoteSeries
+	Regular  QuoteSeries
+	Post     QuoteSeries
+	Segments SegmentList // 当天的交易时段(如午休前后的两个盘中时段)，旧数据为空
+	Source   string      // 抓取到该报价的数据源，不序列化
+	Events   EventList   // 当天的分红、拆股、财报等事件，单独保存为附件，不序列化
 }
 
 // Marshal 序列化
@@ -134,6 +137,11 @@ func (q CompanyDailyQuote) Marshal() []byte {
 	buffer = append(buffer, q.Regular.Marshal()...)
 	buffer = append(buffer, q.Post.Marshal()...)
 
+	// 没有交易时段时与旧格式相同
+	if len(q.Segments) > 0 {
+		buffer = append(buffer, q.Segments.Marshal()...)
+	}
+
 	return buffer
 }
 
@@ -144,6 +152,7 @@ func (q *CompanyDailyQuote) Unmarshal(buffer []byte) {
 	q.Pre.Unmarshal(buffer[companySize:])
 	q.Regular.Unmarshal(buffer[companySize+q.Pre.Len():])
 	q.Post.Unmarshal(buffer[companySize+q.Pre.Len()+q.Regular.Len():])
+	q.Segments.Unmarshal(buffer[companySize+q.Pre.Len()+q.Regular.Len()+q.Post.Len():])
 }
 
 // Equal 判断是否相等
@@ -171,6 +180,11 @@ func (q CompanyDailyQuote) Equal(s CompanyDailyQuote) error {
 		return fmt.Errorf("CompanyDailyQuote Post不相等:%v", err)
 	}
 
+	err = q.Segments.Equal(s.Segments)
+	if err != nil {
+		return fmt.Errorf("CompanyDailyQuote Segments不相等:%v", err)
+	}
+
 	return nil
 }
 
@@ -0,0 +1,130 @@
+package market
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/nzai/stockrecorder/calendar"
+)
+
+// testSeries 从start开始每分钟一笔报价的序列
+func testSeries(start uint32, count int) QuoteSeries {
+
+	series := QuoteSeries{Count: uint32(count)}
+	for index := 0; index < count; index++ {
+		price := uint32(10000 + index)
+		series.Timestamp = append(series.Timestamp, start+uint32(index*60))
+		series.Open = append(series.Open, price)
+		series.Close = append(series.Close, price+1)
+		series.Max = append(series.Max, price+2)
+		series.Min = append(series.Min, price-1)
+		series.Volume = append(series.Volume, uint32(100*(index+1)))
+	}
+
+	return series
+}
+
+func TestCompanyDailyQuoteFormat(t *testing.T) {
+
+	date := time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)
+	open := uint32(date.Add(time.Hour * 14).Unix())
+
+	plain := CompanyDailyQuote{
+		Company: Company{Code: "AAPL", Name: "Apple Inc."},
+		Pre:     testSeries(open-3600, 3),
+		Regular: testSeries(open, 5),
+		Post:    testSeries(open+23400, 2),
+	}
+
+	// 没有交易时段时与旧格式相同
+	old := oldCompany("AAPL", "Apple Inc.")
+	old = append(old, plain.Pre.Marshal()...)
+	old = append(old, plain.Regular.Marshal()...)
+	old = append(old, plain.Post.Marshal()...)
+	if !bytes.Equal(plain.Marshal(), old) {
+		t.Fatal("没有交易时段的报价序列化后与旧格式不同")
+	}
+
+	segmented := CompanyDailyQuote{
+		Company: Company{Code: "0700", Name: "騰訊控股", Exchange: "HKEX", LotSize: 100},
+		Regular: testSeries(open, 10),
+		Segments: SegmentList{
+			{Name: "morning", Type: calendar.SessionRegular, Start: open, End: open + 300},
+			{Name: "afternoon", Type: calendar.SessionRegular, Start: open + 300, End: open + 600},
+		},
+	}
+
+	for _, quote := range []CompanyDailyQuote{plain, segmented} {
+
+		var read CompanyDailyQuote
+		read.Unmarshal(quote.Marshal())
+
+		err := read.Equal(quote)
+		if err != nil {
+			t.Errorf("%s: %v", quote.Code, err)
+		}
+
+		err = read.Company.Equal(quote.Company)
+		if err != nil {
+			t.Errorf("%s: %v", quote.Code, err)
+		}
+	}
+
+	// 按时段取报价
+	var read CompanyDailyQuote
+	read.Unmarshal(segmented.Marshal())
+	if morning := read.Series("morning"); morning.Count != 5 || morning.Timestamp[0] != open {
+		t.Errorf("morning时段的报价不正确: %+v", morning)
+	}
+}
+
+func TestDailyQuoteFormat(t *testing.T) {
+
+	location, _ := time.LoadLocation(America{}.Timezone())
+	date := time.Date(2018, 1, 2, 0, 0, 0, 0, location)
+	open := uint32(date.Add(time.Hour*9 + time.Minute*30).Unix())
+	_, offset := date.Zone()
+
+	// 新旧格式的公司混合保存在同一天的数据中
+	dailyQuote := DailyQuote{
+		Market:    America{},
+		Date:      date,
+		UTCOffset: offset,
+		Quotes: []CompanyDailyQuote{
+			{
+				Company: Company{Code: "AAPL", Name: "Apple Inc."},
+				Regular: testSeries(open, 3),
+			},
+			{
+				Company:  Company{Code: "MSFT", Name: "Microsoft", Exchange: "NASDAQ", Type: "stock", Currency: "USD"},
+				Pre:      testSeries(open-600, 2),
+				Regular:  testSeries(open, 4),
+				Segments: SegmentList{{Name: "pre", Type: calendar.SessionPre, Start: open - 19800, End: open}, {Name: "regular", Type: calendar.SessionRegular, Start: open, End: open + 23400}},
+			},
+			{
+				Company: Company{Code: "SPY", Name: "SPDR S&P 500"},
+				Regular: testSeries(open, 1),
+			},
+		},
+	}
+
+	read := DailyQuote{Market: America{}}
+	read.Unmarshal(dailyQuote.Marshal())
+
+	if len(read.Quotes) != len(dailyQuote.Quotes) {
+		t.Fatalf("读取了%d家公司，应为%d家", len(read.Quotes), len(dailyQuote.Quotes))
+	}
+
+	err := read.Equal(dailyQuote)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for index, quote := range dailyQuote.Quotes {
+		err = read.Quotes[index].Company.Equal(quote.Company)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
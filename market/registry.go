@@ -0,0 +1,55 @@
+package market
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// registry 已注册的市场
+	registry = map[string]Market{}
+	// registryMutex 市场注册表锁
+	registryMutex sync.RWMutex
+)
+
+// Register 注册市场，之后可以按名称(不区分大小写)获取，同名的市场会被替换
+func Register(m Market) {
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	registry[strings.ToLower(m.Name())] = m
+}
+
+// Get 按名称获取已注册的市场
+func Get(name string) (Market, error) {
+
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	m, found := registry[strings.ToLower(name)]
+	if !found {
+		return nil, ErrUnknownMarket
+	}
+
+	return m, nil
+}
+
+// Registered 已注册的市场，按名称排序
+func Registered() []Market {
+
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	markets := make([]Market, 0, len(registry))
+	for _, m := range registry {
+		markets = append(markets, m)
+	}
+
+	sort.Slice(markets, func(i, j int) bool {
+		return markets[i].Name() < markets[j].Name()
+	})
+
+	return markets
+}
@@ -0,0 +1,167 @@
+package market
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/nzai/stockrecorder/calendar"
+)
+
+const (
+	// segmentsMagic 报价序列之后的分段信息标记，旧格式在Post之后直接是下一家公司(代码的首字节不会是0xFF)或数据结束
+	segmentsMagic = 0xFF
+)
+
+// Segment 报价所属的交易时段
+type Segment struct {
+	Name  string // 名称，如 morning、afternoon
+	Type  string // 所属的报价序列: pre、regular、post
+	Start uint32 // 开始时间戳(含)
+	End   uint32 // 结束时间戳(不含)
+}
+
+// Contains 时间戳是否在时段内
+func (s Segment) Contains(timestamp uint32) bool {
+	return timestamp >= s.Start && timestamp < s.End
+}
+
+// SegmentList 交易时段列表
+type SegmentList []Segment
+
+// NewSegments 由交易日历的交易时段构造
+func NewSegments(sessions []calendar.Session) SegmentList {
+
+	segments := make(SegmentList, 0, len(sessions))
+	for _, session := range sessions {
+		segments = append(segments, Segment{
+			Name:  session.Name,
+			Type:  session.Type,
+			Start: uint32(session.Start.Unix()),
+			End:   uint32(session.End.Unix()),
+		})
+	}
+
+	return segments
+}
+
+// Marshal 序列化，标记(1字节)、数量(2字节)，之后每个时段依次为名称、类型(各2字节长度+内容)、开始及结束时间戳(各4字节)
+func (l SegmentList) Marshal() []byte {
+
+	buffer := []byte{segmentsMagic, 0, 0}
+	binary.BigEndian.PutUint16(buffer[1:3], uint16(len(l)))
+
+	timestamps := make([]byte, 8)
+	for _, segment := range l {
+		buffer = appendString(buffer, segment.Name)
+		buffer = appendString(buffer, segment.Type)
+
+		binary.BigEndian.PutUint32(timestamps[:4], segment.Start)
+		binary.BigEndian.PutUint32(timestamps[4:], segment.End)
+		buffer = append(buffer, timestamps...)
+	}
+
+	return buffer
+}
+
+// Unmarshal 反序列化，buffer不以标记开头时(旧格式)返回0，否则返回读取的字节数
+func (l *SegmentList) Unmarshal(buffer []byte) int {
+
+	if len(buffer) < 3 || buffer[0] != segmentsMagic {
+		return 0
+	}
+
+	count := int(binary.BigEndian.Uint16(buffer[1:3]))
+	offset := 3
+
+	segments := make(SegmentList, 0, count)
+	for index := 0; index < count; index++ {
+
+		var segment Segment
+		segment.Name, offset = readString(buffer, offset)
+		segment.Type, offset = readString(buffer, offset)
+		segment.Start = binary.BigEndian.Uint32(buffer[offset : offset+4])
+		segment.End = binary.BigEndian.Uint32(buffer[offset+4 : offset+8])
+		offset += 8
+
+		segments = append(segments, segment)
+	}
+
+	*l = segments
+
+	return offset
+}
+
+// Equal 是否相同
+func (l SegmentList) Equal(s SegmentList) error {
+
+	if len(l) != len(s) {
+		return fmt.Errorf("SegmentList数量不相等:%d %d", len(l), len(s))
+	}
+
+	for index := range l {
+		if l[index] != s[index] {
+			return fmt.Errorf("第%d个Segment不相等:[%+v] [%+v]", index, l[index], s[index])
+		}
+	}
+
+	return nil
+}
+
+// Find 时间戳所在的时段
+func (l SegmentList) Find(timestamp uint32) (Segment, bool) {
+
+	for _, segment := range l {
+		if segment.Contains(timestamp) {
+			return segment, true
+		}
+	}
+
+	return Segment{}, false
+}
+
+// Series 某个时段的报价，没有该时段时返回空序列
+func (q CompanyDailyQuote) Series(name string) QuoteSeries {
+
+	for _, segment := range q.Segments {
+
+		if segment.Name != name {
+			continue
+		}
+
+		var series QuoteSeries
+		switch segment.Type {
+		case calendar.SessionPre:
+			series = q.Pre
+		case calendar.SessionPost:
+			series = q.Post
+		default:
+			series = q.Regular
+		}
+
+		return series.Between(segment.Start, segment.End)
+	}
+
+	return QuoteSeries{}
+}
+
+// Between 时间戳在[start, end)内的报价
+func (s QuoteSeries) Between(start, end uint32) QuoteSeries {
+
+	var result QuoteSeries
+	for index := 0; index < int(s.Count); index++ {
+
+		if s.Timestamp[index] < start || s.Timestamp[index] >= end {
+			continue
+		}
+
+		result.Count++
+		result.Timestamp = append(result.Timestamp, s.Timestamp[index])
+		result.Open = append(result.Open, s.Open[index])
+		result.Close = append(result.Close, s.Close[index])
+		result.Max = append(result.Max, s.Max[index])
+		result.Min = append(result.Min, s.Min[index])
+		result.Volume = append(result.Volume, s.Volume[index])
+	}
+
+	return result
+}
@@ -0,0 +1,80 @@
+package market
+
+import (
+	"testing"
+
+	"github.com/nzai/stockrecorder/calendar"
+)
+
+func TestSegmentListFormat(t *testing.T) {
+
+	segments := SegmentList{
+		{Name: "morning", Type: calendar.SessionRegular, Start: 1514856600, End: 1514865600},
+		{Name: "afternoon", Type: calendar.SessionRegular, Start: 1514869200, End: 1514880000},
+	}
+
+	buffer := segments.Marshal()
+	if buffer[0] != segmentsMagic {
+		t.Fatalf("序列化后应以标记0x%X开头: 0x%X", segmentsMagic, buffer[0])
+	}
+
+	// 后面紧跟其他数据时只读取时段本身
+	var read SegmentList
+	size := read.Unmarshal(append(buffer, 'A', 'A', 'P', 'L'))
+	if size != len(buffer) {
+		t.Fatalf("读取了%d字节，应为%d字节", size, len(buffer))
+	}
+
+	err := read.Equal(segments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有时段时也写入标记，读取后为空
+	var empty SegmentList
+	if size := empty.Unmarshal(SegmentList(nil).Marshal()); size != 3 || len(empty) != 0 {
+		t.Errorf("空的时段列表读取了%d字节、%d个时段，应为3字节、0个时段", size, len(empty))
+	}
+}
+
+func TestSegmentListOldFormat(t *testing.T) {
+
+	// 旧格式在报价序列之后直接是下一家公司的代码或数据结束，不以标记开头
+	for _, buffer := range [][]byte{nil, {}, []byte("AAPL"), {segmentsMagic}, {segmentsMagic, 0}} {
+
+		var read SegmentList
+		if size := read.Unmarshal(buffer); size != 0 || read != nil {
+			t.Errorf("%v不是时段列表，读取了%d字节、%d个时段", buffer, size, len(read))
+		}
+	}
+}
+
+func TestSegmentListFind(t *testing.T) {
+
+	segments := SegmentList{
+		{Name: "morning", Type: calendar.SessionRegular, Start: 100, End: 200},
+		{Name: "afternoon", Type: calendar.SessionRegular, Start: 300, End: 400},
+	}
+
+	tests := []struct {
+		timestamp uint32
+		name      string
+		found     bool
+	}{
+		{99, "", false},
+		{100, "morning", true},
+		{199, "morning", true},
+		// 结束时间不含，午休不属于任何时段
+		{200, "", false},
+		{250, "", false},
+		{300, "afternoon", true},
+		{400, "", false},
+	}
+
+	for _, test := range tests {
+		segment, found := segments.Find(test.timestamp)
+		if found != test.found || segment.Name != test.name {
+			t.Errorf("%d所在的时段为%s(%v)，应为%s(%v)", test.timestamp, segment.Name, found, test.name, test.found)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package market
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// WatchlistReplace 只使用文件中的公司
+	WatchlistReplace = "replace"
+	// WatchlistMerge 合并文件中的公司与交易所网站的上市公司列表，文件中为空的字段沿用网站上的值
+	WatchlistMerge = "merge"
+)
+
+// WatchlistConfig 按市场配置的上市公司文件
+type WatchlistConfig struct {
+	File string `yaml:"file"` // 文件路径，按扩展名解析: .csv、.json、.yaml/.yml
+	Mode string `yaml:"mode"` // replace: 只使用文件中的公司(默认)；merge: 与交易所网站的列表合并，网站无法访问时只使用文件
+}
+
+// Watchlist 从本地文件读取上市公司的市场，其他方法由被包装的市场提供
+type Watchlist struct {
+	Market
+	config WatchlistConfig
+}
+
+// NewWatchlist 包装市场，从本地文件读取上市公司
+func NewWatchlist(m Market, config WatchlistConfig) (*Watchlist, error) {
+
+	switch config.Mode {
+	case "":
+		config.Mode = WatchlistReplace
+	case WatchlistReplace, WatchlistMerge:
+	default:
+		return nil, fmt.Errorf("[%s] 未知的上市公司文件模式:%s", m.Name(), config.Mode)
+	}
+
+	// 启动时检查一次文件，每次获取上市公司时重新读取，修改文件后不需要重启
+	_, err := LoadCompaniesFile(config.File)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] %v", m.Name(), err)
+	}
+
+	return &Watchlist{Market: m, config: config}, nil
+}
+
+// Companies 上市公司
+func (m Watchlist) Companies() ([]Company, error) {
+
+	listed, err := LoadCompaniesFile(m.config.File)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.config.Mode == WatchlistReplace {
+		return listed, nil
+	}
+
+	live, err := m.Market.Companies()
+	if err != nil {
+		log.Printf("[%s] 获取上市公司列表时发生错误，只使用%s中的%d家公司: %v", m.Name(), m.config.File, len(listed), err)
+		return listed, nil
+	}
+
+	// 文件中没有填写的字段沿用网站上的值
+	dict := make(map[string]Company, len(live))
+	for _, company := range live {
+		dict[company.Code] = company
+	}
+
+	for index, company := range listed {
+		if current, found := dict[company.Code]; found {
+			listed[index] = company.fill(current)
+		}
+	}
+
+	return mergeCompanies(live, listed), nil
+}
+
+// mergeCompanies 合并上市公司列表，同一代码以后者为准，结果按Code排序
+func mergeCompanies(lists ...[]Company) []Company {
+
+	dict := make(map[string]Company)
+	for _, companies := range lists {
+		for _, company := range companies {
+			dict[company.Code] = company
+		}
+	}
+
+	merged := make([]Company, 0, len(dict))
+	for _, company := range dict {
+		merged = append(merged, company)
+	}
+
+	//	按Code排序
+	sort.Sort(CompanyList(merged))
+
+	return merged
+}
+
+// LoadCompaniesFile 从文件读取上市公司，按扩展名解析: .csv(表头code,name,exchange,type,currency,sector,industry,lotsize，
+// 除code外都可以省略，没有表头时依次为代码、名称)、.json、.yaml/.yml
+func LoadCompaniesFile(path string) ([]Company, error) {
+
+	buffer, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var companies []Company
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		companies, err = parseCompaniesCSV(string(buffer))
+	case ".json":
+		err = json.Unmarshal(buffer, &companies)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(buffer, &companies)
+	default:
+		return nil, fmt.Errorf("不支持的上市公司文件格式:%s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析上市公司文件%s时发生错误: %v", path, err)
+	}
+
+	for index := range companies {
+		companies[index].Code = strings.TrimSpace(companies[index].Code)
+		companies[index].Name = strings.TrimSpace(companies[index].Name)
+
+		code := companies[index].Code
+		if code == "" {
+			return nil, fmt.Errorf("上市公司文件%s的第%d家公司没有代码", path, index+1)
+		}
+
+		// 序列化时代码最长16字节
+		if len(code) > 16 {
+			return nil, fmt.Errorf("上市公司文件%s中的代码%s超过16字节", path, code)
+		}
+	}
+
+	// 去重并按Code排序
+	return mergeCompanies(companies), nil
+}
+
+// parseCompaniesCSV 解析CSV
+func parseCompaniesCSV(content string) ([]Company, error) {
+
+	reader := csv.NewReader(strings.NewReader(content))
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	// 第一行包含code列时作为表头，按列名取值，否则依次为代码、名称
+	columns := map[string]int{"code": 0, "name": 1}
+	header := make(map[string]int, len(records[0]))
+	for index, column := range records[0] {
+		header[strings.ToLower(strings.TrimSpace(column))] = index
+	}
+
+	if _, found := header["code"]; found {
+		columns = header
+		records = records[1:]
+	}
+
+	companies := make([]Company, 0, len(records))
+	for _, parts := range records {
+
+		value := func(column string) string {
+			index, found := columns[column]
+			if !found || index >= len(parts) {
+				return ""
+			}
+			return strings.TrimSpace(parts[index])
+		}
+
+		company := Company{
+			Code:     value("code"),
+			Name:     value("name"),
+			Exchange: value("exchange"),
+			Type:     value("type"),
+			Currency: value("currency"),
+			Sector:   value("sector"),
+			Industry: value("industry"),
+		}
+
+		if lotSize := value("lotsize"); lotSize != "" {
+			size, err := strconv.Atoi(lotSize)
+			if err != nil {
+				return nil, fmt.Errorf("%s的每手股数错误:%s", company.Code, lotSize)
+			}
+			company.LotSize = size
+		}
+
+		companies = append(companies, company)
+	}
+
+	return companies, nil
+}
@@ -0,0 +1,285 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// registry 已注册的指标
+	registry []collector
+	// registryMutex 注册锁
+	registryMutex sync.Mutex
+
+	// DefaultBuckets 默认的直方图分桶(秒)
+	DefaultBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+	// ByteBuckets 按字节数的直方图分桶
+	ByteBuckets = []float64{1 << 10, 16 << 10, 256 << 10, 1 << 20, 4 << 20, 16 << 20, 64 << 20, 256 << 20}
+)
+
+// collector 指标
+type collector interface {
+	// 以Prometheus文本格式输出
+	write(w io.Writer)
+}
+
+// register 注册指标
+func register(c collector) {
+	registryMutex.Lock()
+	registry = append(registry, c)
+	registryMutex.Unlock()
+}
+
+// Handler 以Prometheus文本格式输出所有指标的HTTP处理器
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+		registryMutex.Lock()
+		collectors := append([]collector(nil), registry...)
+		registryMutex.Unlock()
+
+		for _, c := range collectors {
+			c.write(w)
+		}
+	})
+}
+
+// desc 指标描述
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+// header 输出HELP和TYPE
+func (d desc) header(w io.Writer, _type string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, d.help, d.name, _type)
+}
+
+// key 标签值组合的索引
+func (d desc) key(values []string) string {
+
+	if len(values) != len(d.labels) {
+		panic(fmt.Errorf("指标%s需要%d个标签值，实际为%d个", d.name, len(d.labels), len(values)))
+	}
+
+	return strings.Join(values, "\xff")
+}
+
+// format 格式化标签 {a="1",b="2"}，extra为额外的标签(如le)
+func (d desc) format(key string, extra ...string) string {
+
+	var pairs []string
+	if len(d.labels) > 0 {
+		for index, value := range strings.Split(key, "\xff") {
+			pairs = append(pairs, fmt.Sprintf("%s=%s", d.labels[index], strconv.Quote(value)))
+		}
+	}
+
+	for index := 0; index+1 < len(extra); index += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", extra[index], strconv.Quote(extra[index+1])))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// sortedKeys 排序后的索引，保证输出顺序稳定
+func sortedKeys(values map[string]float64) []string {
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// formatFloat 格式化数值
+func formatFloat(value float64) string {
+
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// Counter 计数器
+type Counter struct {
+	desc
+	mutex  sync.Mutex
+	values map[string]float64
+}
+
+// NewCounter 新建并注册计数器
+func NewCounter(name, help string, labels ...string) *Counter {
+
+	c := &Counter{desc: desc{name, help, labels}, values: make(map[string]float64)}
+	register(c)
+
+	return c
+}
+
+// Inc 加1
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add 增加
+func (c *Counter) Add(value float64, labelValues ...string) {
+
+	key := c.key(labelValues)
+
+	c.mutex.Lock()
+	c.values[key] += value
+	c.mutex.Unlock()
+}
+
+// write 输出
+func (c *Counter) write(w io.Writer) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.header(w, "counter")
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.format(key), formatFloat(c.values[key]))
+	}
+}
+
+// Gauge 仪表
+type Gauge struct {
+	desc
+	mutex  sync.Mutex
+	values map[string]float64
+}
+
+// NewGauge 新建并注册仪表
+func NewGauge(name, help string, labels ...string) *Gauge {
+
+	g := &Gauge{desc: desc{name, help, labels}, values: make(map[string]float64)}
+	register(g)
+
+	return g
+}
+
+// Set 设置
+func (g *Gauge) Set(value float64, labelValues ...string) {
+
+	key := g.key(labelValues)
+
+	g.mutex.Lock()
+	g.values[key] = value
+	g.mutex.Unlock()
+}
+
+// Add 增加
+func (g *Gauge) Add(value float64, labelValues ...string) {
+
+	key := g.key(labelValues)
+
+	g.mutex.Lock()
+	g.values[key] += value
+	g.mutex.Unlock()
+}
+
+// write 输出
+func (g *Gauge) write(w io.Writer) {
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.header(w, "gauge")
+	for _, key := range sortedKeys(g.values) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.format(key), formatFloat(g.values[key]))
+	}
+}
+
+// Histogram 直方图
+type Histogram struct {
+	desc
+	buckets []float64
+	mutex   sync.Mutex
+	values  map[string]*histogramValue
+}
+
+// histogramValue 直方图的值
+type histogramValue struct {
+	counts []uint64 // 各分桶的计数(不累加)
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram 新建并注册直方图
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	h := &Histogram{desc: desc{name, help, labels}, buckets: buckets, values: make(map[string]*histogramValue)}
+	register(h)
+
+	return h
+}
+
+// Observe 记录一次观测值
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+
+	key := h.key(labelValues)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	v, found := h.values[key]
+	if !found {
+		v = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = v
+	}
+
+	if index := sort.SearchFloat64s(h.buckets, value); index < len(h.buckets) {
+		v.counts[index]++
+	}
+	v.count++
+	v.sum += value
+}
+
+// write 输出
+func (h *Histogram) write(w io.Writer) {
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	keys := make([]string, 0, len(h.values))
+	for key := range h.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	h.header(w, "histogram")
+	for _, key := range keys {
+
+		v := h.values[key]
+
+		var cumulative uint64
+		for index, bound := range h.buckets {
+			cumulative += v.counts[index]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.format(key, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.format(key, "le", "+Inf"), v.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.format(key), formatFloat(v.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.format(key), v.count)
+	}
+}
@@ -0,0 +1,90 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// EmailConfig SMTP邮件配置
+type EmailConfig struct {
+	Address  string   `yaml:"address"`  // SMTP服务器地址，如 smtp.example.com:587
+	Username string   `yaml:"username"` // 用户名，为空时不认证
+	Password string   `yaml:"password"` // 密码
+	From     string   `yaml:"from"`     // 发件人
+	To       []string `yaml:"to"`       // 收件人
+	Events   []string `yaml:"events"`   // 订阅的事件类型，为空时订阅全部
+}
+
+// Email SMTP邮件
+type Email struct {
+	config EmailConfig
+}
+
+// NewEmail 新建SMTP邮件
+func NewEmail(config EmailConfig) *Email {
+	return &Email{config: config}
+}
+
+// Name 名称
+func (e Email) Name() string {
+	return "email " + strings.Join(e.config.To, ",")
+}
+
+// Send 发送
+func (e Email) Send(ctx context.Context, event Event) error {
+
+	if len(e.config.To) == 0 {
+		return fmt.Errorf("没有收件人")
+	}
+
+	var auth smtp.Auth
+	if e.config.Username != "" {
+		host, _, err := net.SplitHostPort(e.config.Address)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", e.config.Username, e.config.Password, host)
+	}
+
+	// net/smtp不支持context，在单独的goroutine中发送，超时或取消时不再等待
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(e.config.Address, auth, e.config.From, e.config.To, e.message(event))
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// message 邮件内容
+func (e Email) message(event Event) []byte {
+
+	buffer := new(bytes.Buffer)
+	fmt.Fprintf(buffer, "From: %s\r\n", e.config.From)
+	fmt.Fprintf(buffer, "To: %s\r\n", strings.Join(e.config.To, ", "))
+	fmt.Fprintf(buffer, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", event.Title()))
+	fmt.Fprintf(buffer, "Date: %s\r\n", event.Time.Format(time.RFC1123Z))
+	fmt.Fprintf(buffer, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(buffer, "Content-Type: text/plain; charset=UTF-8\r\n")
+	fmt.Fprintf(buffer, "Content-Transfer-Encoding: base64\r\n\r\n")
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(event.Text()))
+	for len(encoded) > 76 {
+		buffer.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buffer.WriteString(encoded + "\r\n")
+
+	return buffer.Bytes()
+}
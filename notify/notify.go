@@ -0,0 +1,201 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultFailureRate 默认的失败率告警阈值
+	defaultFailureRate = 0.05
+	// sendTimeout 发送一条通知的超时时间
+	sendTimeout = time.Second * 10
+)
+
+// EventType 事件类型
+type EventType string
+
+const (
+	// DayCompleted 某天的数据已保存
+	DayCompleted EventType = "day_completed"
+	// DayFailed 某天的数据抓取或保存失败
+	DayFailed EventType = "day_failed"
+	// HighFailureRate 某天抓取失败的公司比例过高
+	HighFailureRate EventType = "high_failure_rate"
+	// CompaniesFailed 获取上市公司列表失败
+	CompaniesFailed EventType = "companies_failed"
+	// CompaniesChanged 上市公司列表有变化
+	CompaniesChanged EventType = "companies_changed"
+)
+
+// Event 事件
+type Event struct {
+	Type    EventType              `json:"type"`              // 类型
+	Market  string                 `json:"market"`            // 市场
+	Date    string                 `json:"date,omitempty"`    // 日期
+	Time    time.Time              `json:"time"`              // 发生时间
+	Message string                 `json:"message"`           // 说明
+	Details map[string]interface{} `json:"details,omitempty"` // 明细
+}
+
+// NewEvent 新建事件
+func NewEvent(_type EventType, market string, date time.Time, format string, args ...interface{}) Event {
+
+	event := Event{
+		Type:    _type,
+		Market:  market,
+		Time:    time.Now(),
+		Message: fmt.Sprintf(format, args...),
+	}
+
+	if !date.IsZero() {
+		event.Date = date.Format("20060102")
+	}
+
+	return event
+}
+
+// Title 标题
+func (e Event) Title() string {
+
+	if e.Date == "" {
+		return fmt.Sprintf("[%s] %s", e.Market, e.Type)
+	}
+
+	return fmt.Sprintf("[%s] %s %s", e.Market, e.Date, e.Type)
+}
+
+// Text 正文
+func (e Event) Text() string {
+
+	lines := []string{e.Title(), e.Message}
+
+	keys := make([]string, 0, len(e.Details))
+	for key := range e.Details {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		lines = append(lines, fmt.Sprintf("%s: %v", key, e.Details[key]))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Sink 通知渠道
+type Sink interface {
+	// 名称
+	Name() string
+	// 发送
+	Send(ctx context.Context, event Event) error
+}
+
+// Config 通知配置
+type Config struct {
+	FailureRate float64         `yaml:"failurerate"` // 失败率达到此值时发送high_failure_rate，默认0.05
+	Webhooks    []WebhookConfig `yaml:"webhooks"`    // 通用webhook，POST事件的JSON
+	Slack       []SlackConfig   `yaml:"slack"`       // Slack兼容的webhook
+	Email       []EmailConfig   `yaml:"email"`       // SMTP邮件
+}
+
+// filter 按事件类型过滤的通知渠道
+type filter struct {
+	Sink
+	events map[EventType]bool // 为空时发送全部事件
+}
+
+// accept 是否发送该事件
+func (f filter) accept(_type EventType) bool {
+	return len(f.events) == 0 || f.events[_type]
+}
+
+// newFilter 新建按事件类型过滤的通知渠道
+func newFilter(sink Sink, events []string) filter {
+
+	f := filter{Sink: sink, events: make(map[EventType]bool, len(events))}
+	for _, event := range events {
+		f.events[EventType(strings.TrimSpace(event))] = true
+	}
+
+	return f
+}
+
+// Notifier 通知器，把事件发送到所有订阅了该类型的渠道
+type Notifier struct {
+	failureRate float64
+	sinks       []filter
+}
+
+// New 按配置新建通知器
+func New(config Config) *Notifier {
+
+	n := &Notifier{failureRate: config.FailureRate}
+	if n.failureRate <= 0 {
+		n.failureRate = defaultFailureRate
+	}
+
+	for _, c := range config.Webhooks {
+		n.sinks = append(n.sinks, newFilter(NewWebhook(c), c.Events))
+	}
+
+	for _, c := range config.Slack {
+		n.sinks = append(n.sinks, newFilter(NewSlack(c), c.Events))
+	}
+
+	for _, c := range config.Email {
+		n.sinks = append(n.sinks, newFilter(NewEmail(c), c.Events))
+	}
+
+	return n
+}
+
+// Add 增加通知渠道，events为空时发送全部事件
+func (n *Notifier) Add(sink Sink, events ...string) {
+	n.sinks = append(n.sinks, newFilter(sink, events))
+}
+
+// FailureRate 失败率告警阈值
+func (n *Notifier) FailureRate() float64 {
+
+	if n == nil {
+		return defaultFailureRate
+	}
+
+	return n.failureRate
+}
+
+// Notify 发送事件，发送失败只记录日志，ctx取消时仍然发送
+func (n *Notifier) Notify(ctx context.Context, event Event) error {
+
+	if n == nil {
+		return nil
+	}
+
+	var errs []string
+	for _, sink := range n.sinks {
+
+		if !sink.accept(event.Type) {
+			continue
+		}
+
+		sendCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sendTimeout)
+		err := sink.Send(sendCtx, event)
+		cancel()
+
+		if err != nil {
+			log.Printf("[%s] 发送%s通知到%s时发生错误: %v", event.Market, event.Type, sink.Name(), err)
+			errs = append(errs, fmt.Sprintf("%s: %v", sink.Name(), err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("发送通知时发生错误: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
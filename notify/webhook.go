@@ -0,0 +1,112 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// WebhookConfig 通用webhook配置
+type WebhookConfig struct {
+	URL     string            `yaml:"url"`     // 地址
+	Headers map[string]string `yaml:"headers"` // 额外的请求头，如认证
+	Events  []string          `yaml:"events"`  // 订阅的事件类型，为空时订阅全部
+}
+
+// Webhook 通用webhook，POST事件的JSON
+type Webhook struct {
+	config WebhookConfig
+}
+
+// NewWebhook 新建通用webhook
+func NewWebhook(config WebhookConfig) *Webhook {
+	return &Webhook{config: config}
+}
+
+// Name 名称
+func (w Webhook) Name() string {
+	return "webhook " + w.config.URL
+}
+
+// Send 发送
+func (w Webhook) Send(ctx context.Context, event Event) error {
+
+	buffer, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return postJSON(ctx, w.config.URL, w.config.Headers, buffer)
+}
+
+// SlackConfig Slack兼容的webhook配置
+type SlackConfig struct {
+	URL     string   `yaml:"url"`     // Incoming Webhook地址
+	Channel string   `yaml:"channel"` // 频道，为空时使用webhook默认的频道
+	Events  []string `yaml:"events"`  // 订阅的事件类型，为空时订阅全部
+}
+
+// Slack Slack兼容的webhook，POST {"text": "..."}
+type Slack struct {
+	config SlackConfig
+}
+
+// NewSlack 新建Slack兼容的webhook
+func NewSlack(config SlackConfig) *Slack {
+	return &Slack{config: config}
+}
+
+// Name 名称
+func (s Slack) Name() string {
+	return "slack " + s.config.URL
+}
+
+// Send 发送
+func (s Slack) Send(ctx context.Context, event Event) error {
+
+	payload := struct {
+		Channel string `json:"channel,omitempty"`
+		Text    string `json:"text"`
+	}{s.config.Channel, event.Text()}
+
+	buffer, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	return postJSON(ctx, s.config.URL, nil, buffer)
+}
+
+// postJSON POST JSON，返回非2xx时视为失败
+func postJSON(ctx context.Context, url string, headers map[string]string, body []byte) error {
+
+	request, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/json; charset=utf-8")
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := http.DefaultClient.Do(request.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+		if message = bytes.TrimSpace(message); len(message) > 0 {
+			return fmt.Errorf("HTTP %d: %s", response.StatusCode, message)
+		}
+		return fmt.Errorf("HTTP %d", response.StatusCode)
+	}
+
+	return nil
+}
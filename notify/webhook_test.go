@@ -0,0 +1,102 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookPayload(t *testing.T) {
+
+	var received Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != "POST" {
+			t.Errorf("请求方法为%s，应为POST", r.Method)
+		}
+
+		if contentType := r.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "application/json") {
+			t.Errorf("Content-Type为%s", contentType)
+		}
+
+		if token := r.Header.Get("Authorization"); token != "Bearer secret" {
+			t.Errorf("没有带上配置的请求头，Authorization为%s", token)
+		}
+
+		buffer, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+
+		err = json.Unmarshal(buffer, &received)
+		if err != nil {
+			t.Errorf("解析请求内容%s时发生错误: %v", buffer, err)
+		}
+	}))
+	defer server.Close()
+
+	webhook := NewWebhook(WebhookConfig{URL: server.URL, Headers: map[string]string{"Authorization": "Bearer secret"}})
+
+	event := NewEvent(DayCompleted, "America", time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC), "%d家上市公司", 100)
+	event.Details = map[string]interface{}{"failed": 3}
+
+	err := webhook.Send(context.Background(), event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if received.Type != DayCompleted || received.Market != "America" || received.Date != "20180102" || received.Message != "100家上市公司" {
+		t.Errorf("收到的事件不正确: %+v", received)
+	}
+
+	if failed, _ := received.Details["failed"].(float64); failed != 3 {
+		t.Errorf("收到的明细不正确: %v", received.Details)
+	}
+}
+
+func TestWebhookFailure(t *testing.T) {
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	delivered := 0
+	working := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered++
+	}))
+	defer working.Close()
+
+	event := NewEvent(DayFailed, "China", time.Time{}, "保存失败")
+
+	// 非2xx视为失败，错误中带上状态码和响应内容
+	err := NewWebhook(WebhookConfig{URL: failing.URL}).Send(context.Background(), event)
+	if err == nil || !strings.Contains(err.Error(), "HTTP 503") || !strings.Contains(err.Error(), "service unavailable") {
+		t.Fatalf("返回503时的错误不正确: %v", err)
+	}
+
+	// 一个渠道失败时其他渠道照常发送，Notify返回失败的渠道
+	n := New(Config{Webhooks: []WebhookConfig{{URL: failing.URL}, {URL: working.URL}}})
+
+	err = n.Notify(context.Background(), event)
+	if err == nil || !strings.Contains(err.Error(), failing.URL) {
+		t.Errorf("Notify的错误不正确: %v", err)
+	}
+
+	if delivered != 1 {
+		t.Errorf("正常的渠道收到了%d次通知，应为1次", delivered)
+	}
+
+	// 不订阅该事件的渠道不发送
+	n = New(Config{Webhooks: []WebhookConfig{{URL: failing.URL, Events: []string{string(DayCompleted)}}}})
+
+	err = n.Notify(context.Background(), event)
+	if err != nil {
+		t.Errorf("没有订阅的事件不应发送: %v", err)
+	}
+}
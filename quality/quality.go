@@ -0,0 +1,445 @@
+package quality
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nzai/stockrecorder/calendar"
+	"github.com/nzai/stockrecorder/market"
+)
+
+const (
+	// ReportName 质量报告保存到存储时的附件名
+	ReportName = "quality.json"
+	// defaultMaxGap 默认的可疑缺口间隔
+	defaultMaxGap = time.Minute * 15
+)
+
+// Policy 发现问题时的处理方式
+type Policy string
+
+const (
+	// PolicyFlag 保留并记录到报告，默认
+	PolicyFlag Policy = "flag"
+	// PolicyDrop 丢弃有问题的报价
+	PolicyDrop Policy = "drop"
+	// PolicyRepair 能修复的修复，不能修复的丢弃
+	PolicyRepair Policy = "repair"
+)
+
+// 问题类型
+const (
+	// KindOHLC 最高价低于最低价
+	KindOHLC = "ohlc"
+	// KindRange 开盘价或收盘价超出最高最低价范围
+	KindRange = "range"
+	// KindTimestamp 时间戳重复或不是递增的
+	KindTimestamp = "timestamp"
+	// KindSession 报价不在所属时段内
+	KindSession = "session"
+	// KindZeroPrice 价格为0
+	KindZeroPrice = "zero"
+	// KindGap 相邻报价的间隔过大
+	KindGap = "gap"
+)
+
+// 处理结果
+const (
+	actionFlagged  = "flagged"
+	actionDropped  = "dropped"
+	actionRepaired = "repaired"
+)
+
+// Config 数据质量校验配置
+type Config struct {
+	Policy Policy        `yaml:"policy"` // 处理方式: flag、drop、repair，默认flag
+	MaxGap time.Duration `yaml:"maxgap"` // 盘中相邻报价间隔超过此值时记录为可疑缺口，默认15分钟
+}
+
+// Validate 校验配置
+func (c Config) Validate() error {
+
+	switch c.Policy {
+	case "", PolicyFlag, PolicyDrop, PolicyRepair:
+		return nil
+	default:
+		return fmt.Errorf("错误的数据质量处理方式:%s", c.Policy)
+	}
+}
+
+// policy 处理方式
+func (c Config) policy() Policy {
+
+	if c.Policy == "" {
+		return PolicyFlag
+	}
+
+	return c.Policy
+}
+
+// maxGap 可疑缺口间隔
+func (c Config) maxGap() time.Duration {
+
+	if c.MaxGap <= 0 {
+		return defaultMaxGap
+	}
+
+	return c.MaxGap
+}
+
+// Issue 数据质量问题
+type Issue struct {
+	Code      string `json:"code"`      // 公司代码
+	Serie     string `json:"serie"`     // pre、regular、post
+	Kind      string `json:"kind"`      // 问题类型
+	Timestamp uint32 `json:"timestamp"` // 报价时间
+	Detail    string `json:"detail"`    // 说明
+	Action    string `json:"action"`    // 处理结果
+}
+
+// Report 某市场某天的数据质量报告
+type Report struct {
+	Market    string         `json:"market"`    // 市场
+	Date      string         `json:"date"`      // 日期
+	Policy    Policy         `json:"policy"`    // 处理方式
+	Companies int            `json:"companies"` // 公司数
+	Bars      int            `json:"bars"`      // 校验后的报价数
+	Counts    map[string]int `json:"counts"`    // 各类问题的数量
+	Issues    []Issue        `json:"issues"`    // 问题明细
+}
+
+// Marshal 序列化为JSON
+func (r Report) Marshal() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// Unmarshal 从JSON反序列化
+func (r *Report) Unmarshal(buffer []byte) error {
+	return json.Unmarshal(buffer, r)
+}
+
+// String 摘要
+func (r Report) String() string {
+
+	kinds := make([]string, 0, len(r.Counts))
+	for kind := range r.Counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	text := fmt.Sprintf("%d家公司%d笔报价，发现%d个问题(%s)", r.Companies, r.Bars, len(r.Issues), r.Policy)
+	for _, kind := range kinds {
+		text += fmt.Sprintf(" %s:%d", kind, r.Counts[kind])
+	}
+
+	return text
+}
+
+// Validate 按配置校验一天的报价，返回处理后的报价和质量报告
+func Validate(config Config, _market market.Market, date time.Time, quotes []market.CompanyDailyQuote) ([]market.CompanyDailyQuote, Report) {
+
+	report := Report{
+		Market:    _market.Name(),
+		Date:      date.Format("20060102"),
+		Policy:    config.policy(),
+		Companies: len(quotes),
+		Counts:    make(map[string]int),
+	}
+
+	dayStart := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	regulars := regularSpans(_market, dayStart)
+	pre := []span{{dayStart, regulars[0].from}}
+	post := []span{{regulars[len(regulars)-1].to, dayStart.AddDate(0, 0, 1)}}
+
+	v := validator{config: config, policy: config.policy()}
+	validated := make([]market.CompanyDailyQuote, 0, len(quotes))
+	for _, quote := range quotes {
+
+		quote.Pre = v.series(quote.Code, "pre", quote.Pre, pre, false)
+		quote.Regular = v.series(quote.Code, "regular", quote.Regular, regulars, true)
+		quote.Post = v.series(quote.Code, "post", quote.Post, post, false)
+
+		report.Bars += int(quote.Pre.Count + quote.Regular.Count + quote.Post.Count)
+		validated = append(validated, quote)
+	}
+
+	report.Issues = v.issues
+	for _, issue := range v.issues {
+		report.Counts[issue.Kind]++
+	}
+
+	return validated, report
+}
+
+// span 时段[from, to)
+type span struct {
+	from, to time.Time
+}
+
+// regularSpans 盘中时段，有午休的市场为多个，交易日历没有时段时为开盘至收盘
+func regularSpans(_market market.Market, date time.Time) []span {
+
+	var spans []span
+	for _, session := range _market.Sessions(date) {
+		if session.Type == calendar.SessionRegular {
+			spans = append(spans, span{session.Start, session.End})
+		}
+	}
+
+	if len(spans) == 0 {
+		c := _market.Calendar()
+		spans = append(spans, span{c.Open(date), c.Close(date)})
+	}
+
+	return spans
+}
+
+// find 时间戳所在的时段序号，不在任何时段内时返回-1
+func find(spans []span, timestamp uint32) int {
+
+	t := time.Unix(int64(timestamp), 0)
+	for index, s := range spans {
+		if !t.Before(s.from) && t.Before(s.to) {
+			return index
+		}
+	}
+
+	return -1
+}
+
+// describe 时段描述，如 09:30-11:30,13:00-15:00
+func describe(spans []span) string {
+
+	texts := make([]string, 0, len(spans))
+	for _, s := range spans {
+		texts = append(texts, s.from.Format("15:04")+"-"+s.to.Format("15:04"))
+	}
+
+	return strings.Join(texts, ",")
+}
+
+// bar 一笔报价
+type bar struct {
+	timestamp, open, close, max, min, volume uint32
+}
+
+// validator 校验器
+type validator struct {
+	config Config
+	policy Policy
+	issues []Issue
+}
+
+// add 记录问题
+func (v *validator) add(code, serie, kind string, timestamp uint32, action, format string, args ...interface{}) {
+	v.issues = append(v.issues, Issue{
+		Code:      code,
+		Serie:     serie,
+		Kind:      kind,
+		Timestamp: timestamp,
+		Detail:    fmt.Sprintf(format, args...),
+		Action:    action,
+	})
+}
+
+// series 校验报价序列，报价应在spans的某个时段内，checkGap时检查同一时段内的缺口(不计午休)
+func (v *validator) series(code, serie string, series market.QuoteSeries, spans []span, checkGap bool) market.QuoteSeries {
+
+	bars := make([]bar, 0, series.Count)
+	for index := 0; index < int(series.Count); index++ {
+		bars = append(bars, bar{
+			timestamp: series.Timestamp[index],
+			open:      series.Open[index],
+			close:     series.Close[index],
+			max:       series.Max[index],
+			min:       series.Min[index],
+			volume:    series.Volume[index],
+		})
+	}
+
+	bars = v.timestamps(code, serie, bars)
+
+	var kept []bar
+	for _, b := range bars {
+		if b, ok := v.bar(code, serie, b, kept, spans); ok {
+			kept = append(kept, b)
+		}
+	}
+
+	if checkGap {
+		for index := 1; index < len(kept); index++ {
+			// 时间戳不递增的已记录过，不再重复计算缺口
+			if kept[index].timestamp <= kept[index-1].timestamp {
+				continue
+			}
+
+			// 跨时段(如午休前后)的间隔不是缺口
+			if find(spans, kept[index].timestamp) != find(spans, kept[index-1].timestamp) {
+				continue
+			}
+
+			if gap := time.Duration(kept[index].timestamp-kept[index-1].timestamp) * time.Second; gap > v.config.maxGap() {
+				v.add(code, serie, KindGap, kept[index-1].timestamp, actionFlagged, "与下一笔报价间隔%s", gap.String())
+			}
+		}
+	}
+
+	result := market.QuoteSeries{Count: uint32(len(kept))}
+	for _, b := range kept {
+		result.Timestamp = append(result.Timestamp, b.timestamp)
+		result.Open = append(result.Open, b.open)
+		result.Close = append(result.Close, b.close)
+		result.Max = append(result.Max, b.max)
+		result.Min = append(result.Min, b.min)
+		result.Volume = append(result.Volume, b.volume)
+	}
+
+	return result
+}
+
+// timestamps 检查时间戳是否严格递增，repair时排序并保留重复时间戳的最后一笔，drop时丢弃不递增的报价
+func (v *validator) timestamps(code, serie string, bars []bar) []bar {
+
+	var last uint32
+	var kept []bar
+	bad := false
+	for index, b := range bars {
+
+		if index == 0 || b.timestamp > last {
+			kept = append(kept, b)
+			last = b.timestamp
+			continue
+		}
+
+		bad = true
+		switch v.policy {
+		case PolicyDrop:
+			v.add(code, serie, KindTimestamp, b.timestamp, actionDropped, "时间戳不晚于前一笔(%d)", last)
+		case PolicyRepair:
+			v.add(code, serie, KindTimestamp, b.timestamp, actionRepaired, "时间戳不晚于前一笔(%d)", last)
+		default:
+			v.add(code, serie, KindTimestamp, b.timestamp, actionFlagged, "时间戳不晚于前一笔(%d)", last)
+			kept = append(kept, b)
+		}
+	}
+
+	if !bad || v.policy != PolicyRepair {
+		return kept
+	}
+
+	// 排序后去重，保留同一时间戳的最后一笔
+	sorted := append([]bar(nil), bars...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].timestamp < sorted[j].timestamp
+	})
+
+	kept = kept[:0]
+	for _, b := range sorted {
+		if len(kept) > 0 && kept[len(kept)-1].timestamp == b.timestamp {
+			kept[len(kept)-1] = b
+			continue
+		}
+		kept = append(kept, b)
+	}
+
+	return kept
+}
+
+// bar 校验一笔报价，返回处理后的报价及是否保留，previous为已保留的报价
+func (v *validator) bar(code, serie string, b bar, previous []bar, spans []span) (bar, bool) {
+
+	// 时段
+	if find(spans, b.timestamp) < 0 {
+		if v.policy == PolicyFlag {
+			v.add(code, serie, KindSession, b.timestamp, actionFlagged, "不在%s内", describe(spans))
+		} else {
+			v.add(code, serie, KindSession, b.timestamp, actionDropped, "不在%s内", describe(spans))
+			return b, false
+		}
+	}
+
+	// 价格为0
+	if b.open == 0 || b.close == 0 || b.max == 0 || b.min == 0 {
+		detail := fmt.Sprintf("open:%d close:%d max:%d min:%d", b.open, b.close, b.max, b.min)
+		switch v.policy {
+		case PolicyFlag:
+			v.add(code, serie, KindZeroPrice, b.timestamp, actionFlagged, "%s", detail)
+		case PolicyRepair:
+			// 用本笔或前一笔的收盘价补齐
+			reference := b.close
+			if reference == 0 {
+				reference = maxOf(b.open, b.max, b.min)
+			}
+			if reference == 0 && len(previous) > 0 {
+				reference = previous[len(previous)-1].close
+			}
+			if reference == 0 {
+				v.add(code, serie, KindZeroPrice, b.timestamp, actionDropped, "%s", detail)
+				return b, false
+			}
+			for _, price := range []*uint32{&b.open, &b.close, &b.max, &b.min} {
+				if *price == 0 {
+					*price = reference
+				}
+			}
+			v.add(code, serie, KindZeroPrice, b.timestamp, actionRepaired, "%s", detail)
+		default:
+			v.add(code, serie, KindZeroPrice, b.timestamp, actionDropped, "%s", detail)
+			return b, false
+		}
+	}
+
+	// 最高最低价
+	kind := ""
+	switch {
+	case b.max < b.min:
+		kind = KindOHLC
+	case b.open > b.max || b.open < b.min || b.close > b.max || b.close < b.min:
+		kind = KindRange
+	}
+
+	if kind != "" {
+		detail := fmt.Sprintf("open:%d close:%d max:%d min:%d", b.open, b.close, b.max, b.min)
+		switch v.policy {
+		case PolicyFlag:
+			v.add(code, serie, kind, b.timestamp, actionFlagged, "%s", detail)
+		case PolicyRepair:
+			b.max, b.min = maxOf(b.open, b.close, b.max, b.min), minOf(b.open, b.close, b.max, b.min)
+			v.add(code, serie, kind, b.timestamp, actionRepaired, "%s", detail)
+		default:
+			v.add(code, serie, kind, b.timestamp, actionDropped, "%s", detail)
+			return b, false
+		}
+	}
+
+	return b, true
+}
+
+// maxOf 最大值
+func maxOf(values ...uint32) uint32 {
+
+	var max uint32
+	for _, value := range values {
+		if value > max {
+			max = value
+		}
+	}
+
+	return max
+}
+
+// minOf 最小值
+func minOf(values ...uint32) uint32 {
+
+	min := values[0]
+	for _, value := range values[1:] {
+		if value < min {
+			min = value
+		}
+	}
+
+	return min
+}
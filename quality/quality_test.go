@@ -0,0 +1,214 @@
+package quality
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nzai/stockrecorder/market"
+)
+
+// testBars 由报价构造序列
+func testBars(bars ...bar) market.QuoteSeries {
+
+	series := market.QuoteSeries{Count: uint32(len(bars))}
+	for _, b := range bars {
+		series.Timestamp = append(series.Timestamp, b.timestamp)
+		series.Open = append(series.Open, b.open)
+		series.Close = append(series.Close, b.close)
+		series.Max = append(series.Max, b.max)
+		series.Min = append(series.Min, b.min)
+		series.Volume = append(series.Volume, b.volume)
+	}
+
+	return series
+}
+
+// testDay 纽交所2018-01-02盘中各类问题各有一笔的报价
+func testDay(t *testing.T) (time.Time, []market.CompanyDailyQuote) {
+
+	location, err := time.LoadLocation(market.America{}.Timezone())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	date := time.Date(2018, 1, 2, 0, 0, 0, 0, location)
+	open := uint32(date.Add(time.Hour*9 + time.Minute*30).Unix())
+	close := uint32(date.Add(time.Hour * 16).Unix())
+
+	quote := market.CompanyDailyQuote{
+		Company: market.Company{Code: "AAPL"},
+		Regular: testBars(
+			bar{open, 10, 11, 12, 9, 100},
+			bar{open + 60, 10, 11, 12, 9, 100},
+			// 重复的时间戳
+			bar{open + 60, 20, 21, 22, 19, 200},
+			// 最低价为0
+			bar{open + 120, 15, 15, 16, 0, 100},
+			// 最高价低于最低价
+			bar{open + 180, 10, 11, 9, 12, 100},
+			// 收盘价高于最高价
+			bar{open + 240, 10, 13, 12, 9, 100},
+			// 与上一笔间隔20分钟
+			bar{open + 1440, 10, 11, 12, 9, 100},
+			// 收盘之后
+			bar{close + 60, 10, 11, 12, 9, 100},
+		),
+	}
+
+	return date, []market.CompanyDailyQuote{quote}
+}
+
+// checkCounts 检查各类问题的数量及处理结果
+func checkCounts(t *testing.T, report Report, actions map[string]string) {
+
+	if len(report.Issues) != len(actions) {
+		t.Errorf("发现%d个问题，应为%d个: %+v", len(report.Issues), len(actions), report.Issues)
+	}
+
+	for _, issue := range report.Issues {
+		if action, found := actions[issue.Kind]; !found || action != issue.Action {
+			t.Errorf("%s问题的处理结果为%s，应为%s", issue.Kind, issue.Action, action)
+		}
+	}
+
+	for kind := range actions {
+		if report.Counts[kind] != 1 {
+			t.Errorf("%s问题有%d个，应为1个", kind, report.Counts[kind])
+		}
+	}
+}
+
+func TestValidateFlag(t *testing.T) {
+
+	date, quotes := testDay(t)
+	validated, report := Validate(Config{}, market.America{}, date, quotes)
+
+	// 默认只记录，不改动报价
+	if report.Policy != PolicyFlag {
+		t.Errorf("默认处理方式为%s，应为flag", report.Policy)
+	}
+
+	if err := validated[0].Regular.Equal(quotes[0].Regular); err != nil {
+		t.Errorf("flag时报价不应改动: %v", err)
+	}
+
+	if report.Companies != 1 || report.Bars != 8 {
+		t.Errorf("报告为%d家公司%d笔报价，应为1家8笔", report.Companies, report.Bars)
+	}
+
+	checkCounts(t, report, map[string]string{
+		KindTimestamp: actionFlagged,
+		KindZeroPrice: actionFlagged,
+		KindOHLC:      actionFlagged,
+		KindRange:     actionFlagged,
+		KindGap:       actionFlagged,
+		KindSession:   actionFlagged,
+	})
+}
+
+func TestValidateDrop(t *testing.T) {
+
+	date, quotes := testDay(t)
+	validated, report := Validate(Config{Policy: PolicyDrop}, market.America{}, date, quotes)
+
+	// 只保留第1、2笔及间隔20分钟的一笔，丢弃后第2笔与其间隔超过15分钟
+	regular := validated[0].Regular
+	if regular.Count != 3 || report.Bars != 3 {
+		t.Fatalf("保留了%d笔报价，应为3笔", regular.Count)
+	}
+
+	source := quotes[0].Regular
+	for index, original := range []int{0, 1, 6} {
+		if regular.Timestamp[index] != source.Timestamp[original] || regular.Open[index] != source.Open[original] {
+			t.Errorf("第%d笔报价应为原来的第%d笔", index, original)
+		}
+	}
+
+	checkCounts(t, report, map[string]string{
+		KindTimestamp: actionDropped,
+		KindZeroPrice: actionDropped,
+		KindOHLC:      actionDropped,
+		KindRange:     actionDropped,
+		KindGap:       actionFlagged,
+		KindSession:   actionDropped,
+	})
+}
+
+func TestValidateRepair(t *testing.T) {
+
+	date, quotes := testDay(t)
+	validated, report := Validate(Config{Policy: PolicyRepair}, market.America{}, date, quotes)
+
+	// 只丢弃收盘之后的一笔
+	regular := validated[0].Regular
+	if regular.Count != 6 {
+		t.Fatalf("保留了%d笔报价，应为6笔", regular.Count)
+	}
+
+	// 重复的时间戳保留最后一笔
+	if regular.Open[1] != 20 {
+		t.Errorf("重复时间戳的开盘价为%d，应保留最后一笔的20", regular.Open[1])
+	}
+
+	// 为0的价格用收盘价补齐
+	if regular.Min[2] != 15 {
+		t.Errorf("补齐后的最低价为%d，应为15", regular.Min[2])
+	}
+
+	// 最高最低价按开盘、收盘价修正
+	if regular.Max[3] != 12 || regular.Min[3] != 9 {
+		t.Errorf("修正后的最高最低价为%d、%d，应为12、9", regular.Max[3], regular.Min[3])
+	}
+
+	if regular.Max[4] != 13 || regular.Min[4] != 9 {
+		t.Errorf("修正后的最高最低价为%d、%d，应为13、9", regular.Max[4], regular.Min[4])
+	}
+
+	checkCounts(t, report, map[string]string{
+		KindTimestamp: actionRepaired,
+		KindZeroPrice: actionRepaired,
+		KindOHLC:      actionRepaired,
+		KindRange:     actionRepaired,
+		KindGap:       actionFlagged,
+		KindSession:   actionDropped,
+	})
+}
+
+func TestValidateLunchBreak(t *testing.T) {
+
+	location, err := time.LoadLocation(market.HongKong{}.Timezone())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	date := time.Date(2018, 1, 2, 0, 0, 0, 0, location)
+	morning := uint32(date.Add(time.Hour*11 + time.Minute*59).Unix())
+	afternoon := uint32(date.Add(time.Hour * 13).Unix())
+
+	quotes := []market.CompanyDailyQuote{{
+		Company: market.Company{Code: "0700"},
+		Regular: testBars(
+			bar{morning, 10, 11, 12, 9, 100},
+			// 午休之中
+			bar{morning + 1800, 10, 11, 12, 9, 100},
+			bar{afternoon, 10, 11, 12, 9, 100},
+		),
+	}}
+
+	// 午休前后的间隔不是缺口，午休之中的报价不在盘中时段内
+	_, report := Validate(Config{}, market.HongKong{}, date, quotes)
+	checkCounts(t, report, map[string]string{KindSession: actionFlagged})
+}
+
+func TestConfigValidate(t *testing.T) {
+
+	for _, policy := range []Policy{"", PolicyFlag, PolicyDrop, PolicyRepair} {
+		if err := (Config{Policy: policy}).Validate(); err != nil {
+			t.Errorf("%s: %v", policy, err)
+		}
+	}
+
+	if err := (Config{Policy: "ignore"}).Validate(); err == nil {
+		t.Error("ignore应为错误的处理方式")
+	}
+}
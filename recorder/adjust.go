@@ -0,0 +1,94 @@
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nzai/stockrecorder/adjust"
+	"github.com/nzai/stockrecorder/market"
+	"github.com/nzai/stockrecorder/store"
+)
+
+// Adjusted 查询一家公司一段时间(含起止日期)的前复权报价，以结束日期为基准，mode为空时使用配置的复权方式，已保存的原始数据不变
+func (r Recorder) Adjusted(ctx context.Context, _market market.Market, code string, start, end time.Time, mode string) ([]adjust.Day, error) {
+	return r.marketRecorder(_market).adjusted(ctx, code, start, end, mode)
+}
+
+// adjusted 查询一家公司一段时间的前复权报价
+func (mr marketRecorder) adjusted(ctx context.Context, code string, start, end time.Time, mode string) ([]adjust.Day, error) {
+
+	if mode == "" {
+		mode = mr.config.Adjust
+	}
+	if mode == "" {
+		mode = adjust.ModeSplit
+	}
+
+	err := adjust.ValidateMode(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	//	日期按市场所在时区计算
+	location := mr.marketNow().Location()
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, location)
+	end = time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, location)
+
+	if end.Before(start) {
+		return nil, fmt.Errorf("[%s] 结束日期%s早于起始日期%s", mr.Name(), end.Format(datePattern), start.Format(datePattern))
+	}
+
+	var days []adjust.Raw
+	for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		if !mr.Market.Calendar().IsTradingDay(date) {
+			continue
+		}
+
+		quote, found, err := mr.loadCompany(ctx, date, code)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] 读取%s在%s的报价时发生错误: %v", mr.Name(), code, date.Format(datePattern), err)
+		}
+
+		if found {
+			days = append(days, adjust.Raw{Date: date, Quote: quote})
+		}
+	}
+
+	events, err := mr.events(ctx, code, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	adjusted, skipped := adjust.Adjust(mode, days, events)
+	for _, event := range skipped {
+		log.Printf("[%s] 复权时忽略了事件 %s", mr.Name(), event.String())
+	}
+
+	return adjusted, nil
+}
+
+// loadCompany 读取一家公司某天的报价，当天没有数据或没有该公司时found为false
+func (mr marketRecorder) loadCompany(ctx context.Context, date time.Time, code string) (market.CompanyDailyQuote, bool, error) {
+
+	exists, err := mr.store.Exists(ctx, mr.Market, date)
+	if err != nil || !exists {
+		return market.CompanyDailyQuote{}, false, err
+	}
+
+	quote, err := mr.store.LoadCompany(ctx, mr.Market, date, code)
+	if err == store.ErrCompanyNotFound {
+		return quote, false, nil
+	}
+	if err != nil {
+		return quote, false, err
+	}
+
+	return quote, true, nil
+}
@@ -0,0 +1,129 @@
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nzai/stockrecorder/lease"
+	"github.com/nzai/stockrecorder/market"
+)
+
+// BackfillOptions 补录选项
+type BackfillOptions struct {
+	Start time.Time // 起始日期(含)
+	End   time.Time // 结束日期(含)
+	Codes []string  // 只补录这些公司，为空时补录全部上市公司；只补录已有数据的日期，并与当天其他公司的数据合并
+	Force bool      // 覆盖已存在的数据，只对补录全部上市公司有效
+}
+
+// Backfill 补录某市场一段时间的数据
+func (r Recorder) Backfill(ctx context.Context, _market market.Market, options BackfillOptions) error {
+	return r.marketRecorder(_market).backfill(ctx, options)
+}
+
+// backfill 补录一段时间的数据
+func (mr marketRecorder) backfill(ctx context.Context, options BackfillOptions) error {
+
+	//	日期按市场所在时区计算
+	location := mr.marketNow().Location()
+	start := time.Date(options.Start.Year(), options.Start.Month(), options.Start.Day(), 0, 0, 0, 0, location)
+	end := time.Date(options.End.Year(), options.End.Month(), options.End.Day(), 0, 0, 0, 0, location)
+
+	if end.Before(start) {
+		return fmt.Errorf("[%s] 补录的结束日期%s早于起始日期%s", mr.Name(), end.Format(datePattern), start.Format(datePattern))
+	}
+
+	if start.Before(mr.marketNow().Add(-mr.source.Expiration())) {
+		log.Printf("[%s] 补录的起始日期%s超出了数据源的有效期%s，可能抓取不到数据", mr.Name(), start.Format(datePattern), mr.source.Expiration().String())
+	}
+
+	companies, err := mr.backfillCompanies(ctx, options.Codes)
+	if err != nil {
+		return err
+	}
+	log.Printf("[%s] 补录%s至%s的数据，共%d家上市公司", mr.Name(), start.Format(datePattern), end.Format(datePattern), len(companies))
+
+	for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
+
+		// 跳过非交易日
+		if !mr.isTradingDay(date) {
+			continue
+		}
+
+		exists, err := mr.store.Exists(ctx, mr.Market, date)
+		if err != nil {
+			return err
+		}
+
+		// 只补录部分公司时，当天没有数据则跳过，否则保存后当天会被视为已记录，其他公司再也不会抓取
+		if !exists && len(options.Codes) > 0 {
+			log.Printf("[%s] %s还没有数据，只补录部分公司时跳过，请先补录全部上市公司", mr.Name(), date.Format(datePattern))
+			continue
+		}
+
+		if exists && len(options.Codes) == 0 && !options.Force {
+			log.Printf("[%s] %s的数据已存在，跳过", mr.Name(), date.Format(datePattern))
+			continue
+		}
+
+		// 只补录部分公司时保留当天其他公司的数据
+		var base []market.CompanyDailyQuote
+		if len(options.Codes) > 0 {
+			dailyQuote, err := mr.store.Load(ctx, mr.Market, date)
+			if err != nil {
+				return err
+			}
+			base = dailyQuote.Quotes
+		}
+
+		// 不与正在抓取同一天的其他实例冲突
+		err = mr.withLease(ctx, date, false, func(ctx context.Context) error {
+			return mr.crawl(ctx, companies, date, base)
+		})
+		if err == lease.ErrHeld {
+			return fmt.Errorf("[%s] %s正在由其他实例抓取，请稍后重试", mr.Name(), date.Format(datePattern))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// backfillCompanies 需要补录的公司
+func (mr marketRecorder) backfillCompanies(ctx context.Context, codes []string) ([]market.Company, error) {
+
+	companies, err := mr.companies(ctx, mr.today())
+	if err != nil {
+		return nil, err
+	}
+
+	// 指定了公司时不再筛选
+	if len(codes) == 0 {
+		return mr.filterCompanies(ctx, mr.today(), companies), nil
+	}
+
+	dict := make(map[string]market.Company, len(companies))
+	for _, company := range companies {
+		dict[strings.ToUpper(company.Code)] = company
+	}
+
+	var selected []market.Company
+	for _, code := range codes {
+
+		company, found := dict[strings.ToUpper(code)]
+		if !found {
+			// 已退市的公司不在当前列表中，只按代码补录
+			log.Printf("[%s] 当前上市公司列表中没有%s，只按代码补录", mr.Name(), code)
+			company = market.Company{Code: code}
+		}
+
+		selected = append(selected, company)
+	}
+
+	return selected, nil
+}
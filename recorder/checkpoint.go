@@ -0,0 +1,177 @@
+package recorder
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nzai/go-utility/io"
+	"github.com/nzai/stockrecorder/market"
+)
+
+const (
+	// checkpointHeaderSize 检查点记录头: 长度(4字节) + CRC32(4字节)
+	checkpointHeaderSize = 8
+	// checkpointEventsFlag 长度的最高位表示这是公司事件记录，属于紧随其后的报价记录(报价序列化时不含事件)
+	checkpointEventsFlag = 1 << 31
+)
+
+// checkpoint 抓取检查点，每抓取成功一家公司就追加写入本地文件，进程中断后可以从检查点继续
+type checkpoint struct {
+	path  string
+	file  *os.File
+	mutex sync.Mutex
+}
+
+// openCheckpoint 打开某市场某天的检查点，返回检查点中已抓取的公司报价，dir为空时不使用检查点
+func openCheckpoint(dir string, _market market.Market, date time.Time) (*checkpoint, []market.CompanyDailyQuote, error) {
+
+	if dir == "" {
+		return nil, nil, nil
+	}
+
+	path := filepath.Join(dir, strings.ToLower(_market.Name()), date.Format(datePattern)+".ckp")
+
+	var quotes []market.CompanyDailyQuote
+	if io.IsExists(path) {
+		buffer, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var valid int
+		quotes, valid = readCheckpoint(buffer)
+		if valid < len(buffer) {
+			// 进程中断时最后一条记录可能只写了一半，截掉
+			log.Printf("[%s] %s的检查点末尾有%d字节不完整的记录，已截掉", _market.Name(), date.Format(datePattern), len(buffer)-valid)
+			err = os.Truncate(path, int64(valid))
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0660)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &checkpoint{path: path, file: file}, quotes, nil
+}
+
+// readCheckpoint 读取检查点记录，返回完整的报价记录及其占用的字节数
+func readCheckpoint(buffer []byte) ([]market.CompanyDailyQuote, int) {
+
+	var quotes []market.CompanyDailyQuote
+	var events market.EventList
+	offset, valid := 0, 0
+	for offset+checkpointHeaderSize <= len(buffer) {
+
+		header := binary.BigEndian.Uint32(buffer[offset : offset+4])
+		size := int(header &^ checkpointEventsFlag)
+		sum := binary.BigEndian.Uint32(buffer[offset+4 : offset+8])
+
+		start, end := offset+checkpointHeaderSize, offset+checkpointHeaderSize+size
+		if end > len(buffer) || crc32.ChecksumIEEE(buffer[start:end]) != sum {
+			break
+		}
+		offset = end
+
+		if header&checkpointEventsFlag != 0 {
+			events = nil
+			if err := events.Unmarshal(buffer[start:end]); err != nil {
+				break
+			}
+			continue
+		}
+
+		var quote market.CompanyDailyQuote
+		quote.Unmarshal(buffer[start:end])
+		for _, event := range events {
+			if event.Code == quote.Code {
+				quote.Events = append(quote.Events, event)
+			}
+		}
+		quotes = append(quotes, quote)
+
+		events, valid = nil, end
+	}
+
+	// 末尾没有对应报价的事件记录也截掉
+	return quotes, valid
+}
+
+// Append 追加一家公司的报价，有公司事件时先追加事件记录
+func (c *checkpoint) Append(quote market.CompanyDailyQuote) error {
+
+	if c == nil {
+		return nil
+	}
+
+	var buffer []byte
+	if len(quote.Events) > 0 {
+		events, err := quote.Events.Marshal()
+		if err != nil {
+			return fmt.Errorf("序列化%s的公司事件时发生错误: %v", quote.Code, err)
+		}
+		buffer = appendCheckpointRecord(buffer, events, checkpointEventsFlag)
+	}
+	buffer = appendCheckpointRecord(buffer, quote.Marshal(), 0)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	_, err := c.file.Write(buffer)
+	if err != nil {
+		return fmt.Errorf("写入检查点%s时发生错误: %v", c.path, err)
+	}
+
+	return nil
+}
+
+// appendCheckpointRecord 追加一条检查点记录，flag与长度一起写入记录头
+func appendCheckpointRecord(buffer, data []byte, flag uint32) []byte {
+
+	header := make([]byte, checkpointHeaderSize)
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data))|flag)
+	binary.BigEndian.PutUint32(header[4:8], crc32.ChecksumIEEE(data))
+
+	return append(append(buffer, header...), data...)
+}
+
+// Close 关闭检查点文件
+func (c *checkpoint) Close() error {
+
+	if c == nil {
+		return nil
+	}
+
+	return c.file.Close()
+}
+
+// Remove 当天已保存到存储后删除检查点
+func (c *checkpoint) Remove() error {
+
+	if c == nil {
+		return nil
+	}
+
+	err := c.file.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(c.path)
+}
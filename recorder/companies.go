@@ -0,0 +1,104 @@
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nzai/stockrecorder/market"
+	"github.com/nzai/stockrecorder/notify"
+)
+
+const (
+	// companySnapshotDays 向前查找上市公司快照的最大天数
+	companySnapshotDays = 30
+)
+
+// companies 获取上市公司并保存为date当天的快照，获取失败时使用date之前最近的快照
+func (mr marketRecorder) companies(ctx context.Context, date time.Time) ([]market.Company, error) {
+
+	companies, err := mr.Market.Companies()
+	if err == nil {
+		mr.notifyCompaniesChanged(ctx, date, companies)
+
+		// 快照保存失败不影响抓取
+		if err := mr.store.SaveCompanies(ctx, mr.Market, date, companies); err != nil {
+			log.Printf("[%s] 保存%s的上市公司快照时发生错误: %v", mr.Name(), date.Format(datePattern), err)
+		}
+
+		return companies, nil
+	}
+
+	log.Printf("[%s] 获取上市公司时发生错误，尝试使用最近的快照: %v", mr.Name(), err)
+
+	snapshot, day, found := mr.latestSnapshot(ctx, date)
+	if !found {
+		mr.notifier.Notify(ctx, notify.NewEvent(notify.CompaniesFailed, mr.Name(), date, "获取上市公司时发生错误，且%d天内没有上市公司快照: %v", companySnapshotDays, err))
+		return nil, fmt.Errorf("获取上市公司时发生错误，且%d天内没有上市公司快照: %v", companySnapshotDays, err)
+	}
+
+	log.Printf("[%s] 使用%s的上市公司快照，共%d家上市公司", mr.Name(), day.Format(datePattern), len(snapshot))
+	mr.notifier.Notify(ctx, notify.NewEvent(notify.CompaniesFailed, mr.Name(), date, "获取上市公司时发生错误，已使用%s的快照: %v", day.Format(datePattern), err))
+
+	return snapshot, nil
+}
+
+// latestSnapshot 不晚于date的最近一份上市公司快照
+func (mr marketRecorder) latestSnapshot(ctx context.Context, date time.Time) ([]market.Company, time.Time, bool) {
+
+	for index := 0; index <= companySnapshotDays; index++ {
+
+		if ctx.Err() != nil {
+			return nil, time.Time{}, false
+		}
+
+		day := date.AddDate(0, 0, -index)
+		snapshot, err := mr.store.LoadCompanies(ctx, mr.Market, day)
+		if err == nil && len(snapshot) > 0 {
+			return snapshot, day, true
+		}
+	}
+
+	return nil, time.Time{}, false
+}
+
+// notifyCompaniesChanged 与之前最近的快照比较，有变化时发送通知
+func (mr marketRecorder) notifyCompaniesChanged(ctx context.Context, date time.Time, companies []market.Company) {
+
+	previous, day, found := mr.latestSnapshot(ctx, date.AddDate(0, 0, -1))
+	if !found {
+		return
+	}
+
+	diff := market.DiffCompanies(previous, companies)
+	if diff.Empty() {
+		return
+	}
+
+	log.Printf("[%s] 上市公司与%s相比: %s", mr.Name(), day.Format(datePattern), diff.String())
+
+	event := notify.NewEvent(notify.CompaniesChanged, mr.Name(), date, "上市公司与%s相比: %s", day.Format(datePattern), diff.String())
+	event.Details = map[string]interface{}{
+		"added":   diff.Added,
+		"removed": diff.Removed,
+		"renamed": diff.Renamed,
+	}
+	mr.notifier.Notify(ctx, event)
+}
+
+// DiffCompanies 比较某市场两天的上市公司快照
+func (r Recorder) DiffCompanies(ctx context.Context, _market market.Market, from, to time.Time) (market.CompanyDiff, error) {
+
+	before, err := r.store.LoadCompanies(ctx, _market, from)
+	if err != nil {
+		return market.CompanyDiff{}, fmt.Errorf("[%s] 读取%s的上市公司快照时发生错误: %v", _market.Name(), from.Format(datePattern), err)
+	}
+
+	after, err := r.store.LoadCompanies(ctx, _market, to)
+	if err != nil {
+		return market.CompanyDiff{}, fmt.Errorf("[%s] 读取%s的上市公司快照时发生错误: %v", _market.Name(), to.Format(datePattern), err)
+	}
+
+	return market.DiffCompanies(before, after), nil
+}
@@ -0,0 +1,120 @@
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nzai/stockrecorder/market"
+	"github.com/nzai/stockrecorder/store"
+)
+
+// saveEvents 把当天抓取到的公司事件保存为附件，quotes为本次抓取(含从检查点恢复)的公司，替换这些公司已保存的事件，其他公司的事件保留
+func (mr marketRecorder) saveEvents(ctx context.Context, date time.Time, quotes []market.CompanyDailyQuote) {
+
+	crawled := make(map[string]bool, len(quotes))
+	var events market.EventList
+	for _, quote := range quotes {
+		crawled[quote.Code] = true
+		events = append(events, quote.Events...)
+	}
+
+	saved, err := mr.loadEvents(ctx, date)
+	if err != nil {
+		log.Printf("[%s] 读取%s已保存的公司事件时发生错误: %v", mr.Name(), date.Format(datePattern), err)
+		return
+	}
+
+	// 本次抓取的公司即使已经没有事件，也要去掉原有的
+	var kept market.EventList
+	for _, event := range saved {
+		if !crawled[event.Code] {
+			kept = append(kept, event)
+		}
+	}
+
+	if len(events) == 0 && len(kept) == len(saved) {
+		return
+	}
+
+	buffer, err := kept.Merge(events).Marshal()
+	if err != nil {
+		log.Printf("[%s] 序列化%s的公司事件时发生错误: %v", mr.Name(), date.Format(datePattern), err)
+		return
+	}
+
+	err = mr.store.SaveAttachment(ctx, mr.Market, date, market.EventsName, buffer)
+	if err != nil {
+		log.Printf("[%s] 保存%s的公司事件时发生错误: %v", mr.Name(), date.Format(datePattern), err)
+		return
+	}
+
+	log.Printf("[%s] %s有%d个公司事件", mr.Name(), date.Format(datePattern), len(events))
+}
+
+// loadEvents 读取某天保存的公司事件，没有时返回空列表
+func (mr marketRecorder) loadEvents(ctx context.Context, date time.Time) (market.EventList, error) {
+
+	buffer, err := mr.store.LoadAttachment(ctx, mr.Market, date, market.EventsName)
+	if err == store.ErrAttachmentNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var events market.EventList
+	err = events.Unmarshal(buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// Events 查询一段时间(含起止日期)的公司事件，code为空时返回所有公司的事件
+func (r Recorder) Events(ctx context.Context, _market market.Market, code string, start, end time.Time) (market.EventList, error) {
+	return r.marketRecorder(_market).events(ctx, code, start, end)
+}
+
+// events 查询一段时间的公司事件
+func (mr marketRecorder) events(ctx context.Context, code string, start, end time.Time) (market.EventList, error) {
+
+	//	日期按市场所在时区计算
+	location := mr.marketNow().Location()
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, location)
+	end = time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, location)
+
+	if end.Before(start) {
+		return nil, fmt.Errorf("[%s] 结束日期%s早于起始日期%s", mr.Name(), end.Format(datePattern), start.Format(datePattern))
+	}
+
+	var result market.EventList
+	for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		if !mr.Market.Calendar().IsTradingDay(date) {
+			continue
+		}
+
+		events, err := mr.loadEvents(ctx, date)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] 读取%s的公司事件时发生错误: %v", mr.Name(), date.Format(datePattern), err)
+		}
+
+		for _, event := range events {
+			if code == "" || strings.EqualFold(event.Code, code) {
+				result = append(result, event)
+			}
+		}
+	}
+
+	result.Sort()
+
+	return result, nil
+}
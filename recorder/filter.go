@@ -0,0 +1,80 @@
+package recorder
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nzai/stockrecorder/filter"
+	"github.com/nzai/stockrecorder/market"
+)
+
+const (
+	// volumeLookbackDays 按成交量筛选时向前查找已保存数据的最大交易日数
+	volumeLookbackDays = 5
+)
+
+// filterCompanies 按配置筛选date当天需要抓取的上市公司
+func (mr marketRecorder) filterCompanies(ctx context.Context, date time.Time, companies []market.Company) []market.Company {
+
+	config, found := mr.config.Filters[strings.ToLower(mr.Name())]
+	if !found || config.Empty() {
+		return companies
+	}
+
+	f, err := filter.New(config)
+	if err != nil {
+		log.Printf("[%s] 上市公司筛选配置错误，不筛选: %v", mr.Name(), err)
+		return companies
+	}
+
+	var volumes map[string]uint64
+	if f.TopVolume() > 0 {
+		volumes = mr.previousVolumes(ctx, date)
+	}
+
+	selected := f.Apply(companies, volumes)
+	log.Printf("[%s] 按筛选条件保留%d家上市公司(共%d家)", mr.Name(), len(selected), len(companies))
+
+	return selected
+}
+
+// previousVolumes date之前最近一个已保存的交易日各公司的盘中成交量，没有时返回nil
+func (mr marketRecorder) previousVolumes(ctx context.Context, date time.Time) map[string]uint64 {
+
+	c := mr.Market.Calendar()
+	day := date
+	for index := 0; index < volumeLookbackDays; index++ {
+
+		// 前一个交易日，超出交易日历覆盖的年份时无法判断，不再往前找
+		day = c.PrevTradingDay(day)
+		if !c.Covers(day.Year()) {
+			break
+		}
+
+		exists, err := mr.store.Exists(ctx, mr.Market, day)
+		if err != nil || !exists {
+			continue
+		}
+
+		dailyQuote, err := mr.store.Load(ctx, mr.Market, day)
+		if err != nil {
+			log.Printf("[%s] 读取%s的数据时发生错误，不按成交量筛选: %v", mr.Name(), day.Format(datePattern), err)
+			return nil
+		}
+
+		volumes := make(map[string]uint64, len(dailyQuote.Quotes))
+		for _, quote := range dailyQuote.Quotes {
+			for _, volume := range quote.Regular.Volume {
+				volumes[quote.Code] += uint64(volume)
+			}
+		}
+
+		return volumes
+	}
+
+	log.Printf("[%s] %s之前%d个交易日都没有数据，不按成交量筛选", mr.Name(), date.Format(datePattern), volumeLookbackDays)
+
+	return nil
+}
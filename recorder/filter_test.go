@@ -0,0 +1,132 @@
+package recorder
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nzai/stockrecorder/calendar"
+	"github.com/nzai/stockrecorder/filter"
+	"github.com/nzai/stockrecorder/market"
+	"github.com/nzai/stockrecorder/store"
+)
+
+func TestPreviousVolumes(t *testing.T) {
+
+	// 只覆盖2018年的交易日历
+	err := calendar.Load([]byte(`
+markets:
+  volumetest:
+    open: "09:00"
+    close: "15:00"
+    weekends: [Saturday, Sunday]
+    coverage: [2018, 2018]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_market, err := market.NewCustom(market.CustomConfig{Name: "VolumeTest", Timezone: "UTC", Companies: []market.Company{{Code: "A"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	s := store.NewFileSystem(store.FileSystemConfig{StoreRoot: t.TempDir()})
+	mr := marketRecorder{store: s, Market: _market}
+
+	// 2018年第一个交易日之前超出了覆盖范围，不能无限往前找
+	done := make(chan map[string]uint64)
+	go func() { done <- mr.previousVolumes(ctx, time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)) }()
+
+	select {
+	case volumes := <-done:
+		if volumes != nil {
+			t.Errorf("没有数据时应返回nil: %v", volumes)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("超出交易日历覆盖范围时没有停止查找")
+	}
+
+	// 前一个交易日没有数据时再往前找
+	date := time.Date(2018, 1, 3, 0, 0, 0, 0, time.UTC)
+	quote := market.CompanyDailyQuote{
+		Company: market.Company{Code: "A"},
+		Regular: market.QuoteSeries{Count: 2, Timestamp: []uint32{1, 2}, Open: []uint32{1, 1}, Close: []uint32{1, 1}, Max: []uint32{1, 1}, Min: []uint32{1, 1}, Volume: []uint32{100, 200}},
+	}
+
+	_, err = s.Save(ctx, market.DailyQuote{Market: _market, Date: date, Quotes: []market.CompanyDailyQuote{quote}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	volumes := mr.previousVolumes(ctx, time.Date(2018, 1, 8, 0, 0, 0, 0, time.UTC))
+	if volumes["A"] != 300 {
+		t.Errorf("成交量为%v，应为300", volumes)
+	}
+}
+
+func TestFilterCompanies(t *testing.T) {
+
+	err := calendar.Load([]byte(`
+markets:
+  filtertest:
+    open: "09:00"
+    close: "15:00"
+    weekends: [Saturday, Sunday]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	companies := []market.Company{{Code: "A", Type: "stock"}, {Code: "B", Type: "stock"}, {Code: "C", Type: "stock"}, {Code: "E", Type: "etp"}}
+	_market, err := market.NewCustom(market.CustomConfig{Name: "FilterTest", Timezone: "UTC", Companies: companies})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	s := store.NewFileSystem(store.FileSystemConfig{StoreRoot: t.TempDir()})
+
+	// 前一交易日B的成交量最大
+	var quotes []market.CompanyDailyQuote
+	for code, volume := range map[string]uint32{"A": 100, "B": 300, "C": 200, "E": 900} {
+		quotes = append(quotes, market.CompanyDailyQuote{
+			Company: market.Company{Code: code},
+			Regular: market.QuoteSeries{Count: 1, Timestamp: []uint32{1}, Open: []uint32{1}, Close: []uint32{1}, Max: []uint32{1}, Min: []uint32{1}, Volume: []uint32{volume}},
+		})
+	}
+
+	_, err = s.Save(ctx, market.DailyQuote{Market: _market, Date: time.Date(2018, 1, 5, 0, 0, 0, 0, time.UTC), Quotes: quotes})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		filters  map[string]filter.Config
+		expected string
+	}{
+		// 没有配置当前市场时不筛选
+		{nil, "A,B,C,E"},
+		{map[string]filter.Config{"america": {Types: []string{"stock"}}}, "A,B,C,E"},
+		{map[string]filter.Config{"filtertest": {Types: []string{"stock"}, TopVolume: 2}}, "B,C"},
+		{map[string]filter.Config{"filtertest": {TopVolume: 1, Allow: []string{"A"}}}, "A,E"},
+		// 配置错误时不筛选
+		{map[string]filter.Config{"filtertest": {Include: []string{"("}}}, "A,B,C,E"},
+	}
+
+	for _, test := range tests {
+
+		mr := marketRecorder{store: s, Market: _market, config: Config{Filters: test.filters}}
+
+		var codes []string
+		for _, company := range mr.filterCompanies(ctx, time.Date(2018, 1, 8, 0, 0, 0, 0, time.UTC), companies) {
+			codes = append(codes, company.Code)
+		}
+
+		if selected := strings.Join(codes, ","); selected != test.expected {
+			t.Errorf("%v: 保留了%s，应为%s", test.filters, selected, test.expected)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/nzai/stockrecorder/journal"
+)
+
+// Version 记录器版本，编译时通过 -ldflags "-X github.com/nzai/stockrecorder/recorder.Version=xxx" 设置
+var Version = "dev"
+
+// SetJournal 设置抓取记录，每次抓取(无论成功与否)都会追加一条，为nil时不记录
+func (r *Recorder) SetJournal(j journal.Journal) {
+	r.journal = j
+}
+
+// Journal 查询抓取记录
+func (r Recorder) Journal(ctx context.Context, filter journal.Filter) ([]journal.Run, error) {
+
+	if r.journal == nil {
+		return nil, fmt.Errorf("没有配置抓取记录")
+	}
+
+	return r.journal.List(ctx, filter)
+}
+
+// record 追加一条抓取记录，bytes为写入存储的字节数
+func (mr marketRecorder) record(ctx context.Context, summary *CrawlSummary, start time.Time, bytes int, err error) {
+
+	if mr.journal == nil {
+		return
+	}
+
+	host, _ := os.Hostname()
+	run := journal.Run{
+		Market:    mr.Market.Name(),
+		Date:      summary.Date.Format(datePattern),
+		Start:     start,
+		Duration:  time.Since(start).Seconds(),
+		Result:    journal.Success,
+		Total:     summary.Total,
+		Succeeded: summary.Succeeded,
+		Failed:    summary.Failed(),
+		Passes:    summary.Passes,
+		Sources:   summary.Sources,
+		Bytes:     bytes,
+		Repair:    summary.Repair,
+		Host:      host,
+		Version:   Version,
+	}
+
+	if err != nil {
+		run.Result = journal.Failed
+		if ctx.Err() != nil && err == ctx.Err() {
+			run.Result = journal.Canceled
+		}
+		run.Error = err.Error()
+	}
+
+	// 取消的抓取也要记录下来
+	err = mr.journal.Append(context.WithoutCancel(ctx), mr.Market, run)
+	if err != nil {
+		log.Printf("[%s] 保存%s的抓取记录时发生错误: %v", mr.Market.Name(), summary.Date.Format(datePattern), err)
+	}
+}
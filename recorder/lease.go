@@ -0,0 +1,80 @@
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nzai/stockrecorder/lease"
+)
+
+const (
+	// defaultLeaseTTL 默认的租约有效期
+	defaultLeaseTTL = time.Minute
+)
+
+// SetLocker 设置租约管理，多个记录器实例按市场和日期分配工作，为nil时不使用租约(单实例)
+func (r *Recorder) SetLocker(locker lease.Locker) {
+	r.locker = locker
+}
+
+// leaseTTL 租约有效期
+func (mr marketRecorder) leaseTTL() time.Duration {
+
+	if mr.config.LeaseTTL <= 0 {
+		return defaultLeaseTTL
+	}
+
+	return mr.config.LeaseTTL
+}
+
+// leaseKey 某市场某天的租约键
+func (mr marketRecorder) leaseKey(date time.Time) string {
+	return fmt.Sprintf("stockrecorder:%s:%s", strings.ToLower(mr.Name()), date.Format(datePattern))
+}
+
+// withLease 持有某天的租约执行action，租约丢失时取消action的ctx；wait为false时租约已被持有则返回lease.ErrHeld，
+// 为true时等待持有者完成或崩溃(租约过期)后接手，持有者已保存当天数据时直接返回。
+// 取得租约前其他实例可能已经保存了当天数据，action需要自行检查
+func (mr marketRecorder) withLease(ctx context.Context, date time.Time, wait bool, action func(ctx context.Context) error) error {
+
+	if mr.locker == nil {
+		return action(ctx)
+	}
+
+	ttl, key := mr.leaseTTL(), mr.leaseKey(date)
+	for {
+		l, err := mr.locker.Acquire(ctx, key, ttl)
+		if err == nil {
+			defer l.Release(context.WithoutCancel(ctx))
+
+			leaseCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			go lease.Keep(leaseCtx, l, ttl, func(err error) {
+				log.Printf("[%s] %s的租约续约失败，停止抓取: %v", mr.Name(), date.Format(datePattern), err)
+				cancel()
+			})
+
+			return action(leaseCtx)
+		}
+
+		if err != lease.ErrHeld || !wait {
+			return err
+		}
+
+		log.Printf("[%s] %s的租约由其他实例持有，%s后重试", mr.Name(), date.Format(datePattern), ttl.String())
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(ttl):
+		}
+
+		exists, err := mr.store.Exists(ctx, mr.Market, date)
+		if err != nil || exists {
+			return err
+		}
+	}
+}
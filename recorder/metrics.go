@@ -0,0 +1,20 @@
+package recorder
+
+import (
+	"github.com/nzai/stockrecorder/metrics"
+)
+
+var (
+	// companiesCrawled 抓取成功的公司数
+	companiesCrawled = metrics.NewCounter("stockrecorder_companies_crawled_total", "抓取成功的公司数", "market")
+	// companiesFailed 重试后仍然失败的公司数
+	companiesFailed = metrics.NewCounter("stockrecorder_companies_failed_total", "重试后仍然抓取失败的公司数", "market")
+	// daysRecorded 已保存的交易日数
+	daysRecorded = metrics.NewCounter("stockrecorder_days_recorded_total", "已保存的交易日数", "market")
+	// lastRecordedDate 最近一次保存的交易日
+	lastRecordedDate = metrics.NewGauge("stockrecorder_last_recorded_date_seconds", "最近一次保存的交易日(Unix时间)", "market")
+	// lastSuccess 最近一次成功保存的时间
+	lastSuccess = metrics.NewGauge("stockrecorder_last_success_timestamp_seconds", "最近一次成功保存交易日数据的时间(Unix时间)", "market")
+	// qualityIssues 数据质量问题数
+	qualityIssues = metrics.NewCounter("stockrecorder_quality_issues_total", "保存前校验发现的数据质量问题数", "market", "kind")
+)
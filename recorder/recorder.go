@@ -1,12 +1,22 @@
 package recorder
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/nzai/stockrecorder/adjust"
+	"github.com/nzai/stockrecorder/calendar"
+	"github.com/nzai/stockrecorder/filter"
+	"github.com/nzai/stockrecorder/journal"
+	"github.com/nzai/stockrecorder/lease"
 	"github.com/nzai/stockrecorder/market"
+	"github.com/nzai/stockrecorder/notify"
+	"github.com/nzai/stockrecorder/quality"
 	"github.com/nzai/stockrecorder/source"
 	"github.com/nzai/stockrecorder/store"
 )
@@ -15,29 +25,81 @@ const (
 	datePattern = "20060102"
 )
 
+// Config 记录器配置
+type Config struct {
+	CheckpointDir string                   `yaml:"checkpoint"` // 检查点目录，为空时不使用检查点
+	Schedules     map[string][]string      `yaml:"schedules"`  // 各市场的定时任务，如 close+45m 或cron表达式，默认每天0点
+	Validation    quality.Config           `yaml:"validation"` // 保存前的数据质量校验
+	Notify        notify.Config            `yaml:"notify"`     // 通知
+	LeaseTTL      time.Duration            `yaml:"leasettl"`   // 多实例时每个市场每天的租约有效期，默认1分钟
+	Filters       map[string]filter.Config `yaml:"filters"`    // 各市场的上市公司筛选
+	Adjust        string                   `yaml:"adjust"`     // 查询复权报价时默认的复权方式: none、split(默认)、dividend
+}
+
+// Validate 校验配置
+func (c Config) Validate() error {
+
+	err := c.Validation.Validate()
+	if err != nil {
+		return err
+	}
+
+	err = adjust.ValidateMode(c.Adjust)
+	if err != nil {
+		return err
+	}
+
+	for name, f := range c.Filters {
+		err = f.Validate()
+		if err != nil {
+			return fmt.Errorf("[%s] %v", name, err)
+		}
+	}
+
+	for name, specs := range c.Schedules {
+		_, err = parseSchedules(specs, calendar.Get(name))
+		if err != nil {
+			return fmt.Errorf("[%s] %v", name, err)
+		}
+	}
+
+	return nil
+}
+
 // Recorder 股票记录器
 type Recorder struct {
-	source  source.Source   // 数据源
-	store   store.Store     // 存储
-	markets []market.Market // 市场
+	config   Config             // 配置
+	source   source.Source      // 数据源
+	store    store.Store        // 存储
+	markets  []market.Market    // 市场
+	statuses map[string]*status // 各市场记录器的状态
+	notifier *notify.Notifier   // 通知器
+	locker   lease.Locker       // 租约管理，为nil时不使用租约
+	journal  journal.Journal    // 抓取记录，为nil时不记录
 }
 
 // NewRecorder 新建Recorder
-func NewRecorder(source source.Source, store store.Store, markets ...market.Market) *Recorder {
-	return &Recorder{source, store, markets}
+func NewRecorder(config Config, source source.Source, store store.Store, markets ...market.Market) *Recorder {
+
+	statuses := make(map[string]*status, len(markets))
+	for _, m := range markets {
+		statuses[m.Name()] = newStatus(m.Name())
+	}
+
+	return &Recorder{config, source, store, markets, statuses, notify.New(config.Notify), nil, nil}
 }
 
-// RunAndWait 执行
-func (r Recorder) RunAndWait() {
+// RunAndWait 执行，直到ctx取消且进行中的任务都已结束
+func (r Recorder) RunAndWait(ctx context.Context) {
 	var wg sync.WaitGroup
 	wg.Add(len(r.markets))
 
 	for _, m := range r.markets {
 		go func(m market.Market) {
 			// 构造记录器
-			mr := marketRecorder{r.source, r.store, m}
+			mr := r.marketRecorder(m)
 			// 启动
-			mr.RunAndWait()
+			mr.RunAndWait(ctx)
 			wg.Done()
 		}(m)
 	}
@@ -45,49 +107,146 @@ func (r Recorder) RunAndWait() {
 	wg.Wait()
 }
 
+// marketRecorder 构造市场记录器
+func (r Recorder) marketRecorder(_market market.Market) marketRecorder {
+
+	// 补抓等不在运行中的市场使用独立的状态
+	s, found := r.statuses[_market.Name()]
+	if !found {
+		s = newStatus(_market.Name())
+	}
+
+	return marketRecorder{r.config, r.source, r.store, s, r.notifier, r.locker, r.journal, _market}
+}
+
 // marketRecorder 市场记录器
 type marketRecorder struct {
-	source        source.Source // 数据源
-	store         store.Store   // 存储
-	market.Market               // 市场
+	config        Config           // 配置
+	source        source.Source    // 数据源
+	store         store.Store      // 存储
+	status        *status          // 状态
+	notifier      *notify.Notifier // 通知器
+	locker        lease.Locker     // 租约管理
+	journal       journal.Journal  // 抓取记录
+	market.Market                  // 市场
 }
 
 // RunAndWait 启动市场记录器
-func (mr marketRecorder) RunAndWait() {
+func (mr marketRecorder) RunAndWait(ctx context.Context) {
 
-	// 获取市场所在地到明天零点的时间差
-	now := mr.marketNow()
-	duration := mr.durationToNextDay(now)
+	schedules, err := parseSchedules(mr.config.Schedules[strings.ToLower(mr.Name())], mr.Market.Calendar())
+	if err != nil {
+		log.Printf("[%s] 定时任务配置错误: %v", mr.Name(), err)
+		mr.status.SetError(err)
+		return
+	}
 
-	// 抓取历史数据
-	go func(todayZero time.Time) {
-		log.Printf("[%s] 获取历史数据开始", mr.Name())
-		err := mr.crawlHistoryData(todayZero)
-		if err != nil {
-			log.Printf("[%s] 获取历史数据时发生错误: %v", mr.Name(), err)
-			return
+	// 抓取历史数据(不含今天)
+	now := mr.marketNow()
+	log.Printf("[%s] 获取历史数据开始", mr.Name())
+	mr.status.SetHistory(true)
+	err = mr.crawlHistoryData(ctx, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()))
+	mr.status.SetHistory(false)
+	if err != nil {
+		log.Printf("[%s] 获取历史数据时发生错误: %v", mr.Name(), err)
+		mr.status.SetError(err)
+		if ctx.Err() == nil {
+			mr.notifier.Notify(ctx, notify.NewEvent(notify.DayFailed, mr.Name(), time.Time{}, "获取历史数据时发生错误: %v", err))
 		}
+	} else {
 		log.Printf("[%s] 获取历史数据结束", mr.Name())
-	}(now.Add(duration).AddDate(0, 0, -1))
+	}
 
-	// 持续抓取每日数据
+	// 补抓启动前(及获取历史数据期间)错过的最近一次定时任务
+	last := mr.marketNow()
+	if prev := mr.prevRun(schedules, last); !prev.IsZero() && ctx.Err() == nil {
+		log.Printf("[%s] 补抓错过的定时任务(%s)", mr.Name(), prev.Format("2006-01-02 15:04"))
+		mr.runSchedule(ctx, prev)
+	}
+
+	// 按定时任务持续抓取
 	for {
-		log.Printf("[%s] 定时任务已启动，将于%s后激活下一次任务", mr.Name(), duration.String())
-		<-time.After(duration)
-		yesterday := mr.marketNow().AddDate(0, 0, -1)
-		log.Printf("[%s] 获取%s的数据开始", mr.Name(), yesterday.Format(datePattern))
-		err := mr.crawlYesterdayData(yesterday)
-		if err != nil {
-			log.Printf("[%s] 获取%s的数据时发生错误: %v", mr.Name(), yesterday.Format(datePattern), err)
-		} else {
-			log.Printf("[%s] 获取%s的数据结束", mr.Name(), yesterday.Format(datePattern))
+		next := mr.nextRun(schedules, last)
+		if next.IsZero() {
+			log.Printf("[%s] 找不到下一次定时任务，市场记录器已停止", mr.Name())
+			return
+		}
+		mr.status.SetNextRun(next)
+
+		duration := next.Sub(mr.marketNow())
+		log.Printf("[%s] 定时任务已启动，将于%s(%s后)激活下一次任务", mr.Name(), next.Format("2006-01-02 15:04"), duration.String())
+		select {
+		case <-ctx.Done():
+			log.Printf("[%s] 市场记录器已停止", mr.Name())
+			return
+		case <-time.After(duration):
 		}
 
-		// 每天调整延时时长，保证长时间运行的时间精度
-		duration = mr.durationToNextDay(mr.marketNow())
+		mr.runSchedule(ctx, next)
+
+		// 下一次从本次的计划时间之后算起，避免定时器提前唤醒时重复执行
+		last = next
+		if now := mr.marketNow(); now.After(last) {
+			last = now
+		}
 	}
 }
 
+// nextRun 所有定时任务中晚于after的最近一次执行时间
+func (mr marketRecorder) nextRun(schedules []schedule, after time.Time) time.Time {
+
+	var next time.Time
+	for _, s := range schedules {
+		if t := s.Next(after); !t.IsZero() && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+
+	return next
+}
+
+// prevRun 所有定时任务中不晚于before的最近一次执行时间
+func (mr marketRecorder) prevRun(schedules []schedule, before time.Time) time.Time {
+
+	var prev time.Time
+	for _, s := range schedules {
+		if t := s.Prev(before); t.After(prev) {
+			prev = t
+		}
+	}
+
+	return prev
+}
+
+// runSchedule 执行一次定时任务，抓取at时已收市的最近一个交易日
+func (mr marketRecorder) runSchedule(ctx context.Context, at time.Time) {
+
+	date := mr.lastClosedDay(at)
+	log.Printf("[%s] 获取%s的数据开始", mr.Name(), date.Format(datePattern))
+	err := mr.crawlDay(ctx, date)
+	if err != nil {
+		log.Printf("[%s] 获取%s的数据时发生错误: %v", mr.Name(), date.Format(datePattern), err)
+		mr.status.SetError(err)
+		if ctx.Err() == nil {
+			mr.notifier.Notify(ctx, notify.NewEvent(notify.DayFailed, mr.Name(), date, "获取数据时发生错误: %v", err))
+		}
+	} else {
+		log.Printf("[%s] 获取%s的数据结束", mr.Name(), date.Format(datePattern))
+	}
+}
+
+// lastClosedDay at时已收市的最近一个交易日(0点)
+func (mr marketRecorder) lastClosedDay(at time.Time) time.Time {
+
+	c := mr.Market.Calendar()
+	date := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())
+	for !c.IsTradingDay(date) || c.Close(date).After(at) {
+		date = date.AddDate(0, 0, -1)
+	}
+
+	return date
+}
+
 // marketNow 市场所处时区当前时间
 func (mr marketRecorder) marketNow() time.Time {
 	now := time.Now()
@@ -101,82 +260,136 @@ func (mr marketRecorder) marketNow() time.Time {
 	return now.In(location)
 }
 
-// durationToNextDay 现在到明天0点的时间间隔
-func (mr marketRecorder) durationToNextDay(now time.Time) time.Duration {
-
-	year, month, day := now.AddDate(0, 0, 1).Date()
-
-	// 市场所处时区的下一个0点
-	marketTomorrowZero := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
-
-	return marketTomorrowZero.Sub(now)
+// today 市场所处时区今天0点
+func (mr marketRecorder) today() time.Time {
+	now := mr.marketNow()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 }
 
 // crawlHistoryData 抓取历史数据
-func (mr marketRecorder) crawlHistoryData(todayZero time.Time) error {
+func (mr marketRecorder) crawlHistoryData(ctx context.Context, todayZero time.Time) error {
 
 	// 起始日期(含)
 	date := todayZero.Add(-mr.source.Expiration())
 	log.Printf("[%s]抓取历史数据起始日期: %s  结束日期: %s", mr.Name(), date.Format(datePattern), todayZero.Format(datePattern))
 
 	// 获取上市公司
-	companies, err := mr.Market.Companies()
+	companies, err := mr.companies(ctx, todayZero)
 	if err != nil {
 		return err
 	}
 	log.Printf("[%s] 共有%d家上市公司", mr.Name(), len(companies))
+	companies = mr.filterCompanies(ctx, todayZero, companies)
 
-	for date.Before(todayZero) {
+	var uncovered []string
+	for ; date.Before(todayZero); date = date.AddDate(0, 0, 1) {
 
-		// 避免重复记录
-		exists, err := mr.store.Exists(mr.Market, date)
-		if err != nil {
-			return err
+		// 交易日历未覆盖的日期无法记录，结束后作为错误报告
+		if err := mr.checkCoverage(date); err != nil {
+			uncovered = append(uncovered, date.Format(datePattern))
+			continue
 		}
 
-		if !exists {
-			// 抓取那一天的报价
-			err = mr.crawl(companies, date)
-			if err != nil {
+		// 跳过非交易日
+		if !mr.isTradingDay(date) {
+			continue
+		}
+
+		// 其他实例正在抓取的日期跳过
+		err = mr.withLease(ctx, date, false, func(ctx context.Context) error {
+
+			// 避免重复记录
+			exists, err := mr.store.Exists(ctx, mr.Market, date)
+			if err != nil || exists {
 				return err
 			}
+
+			// 抓取那一天的报价
+			return mr.crawl(ctx, companies, date, nil)
+		})
+		if err == lease.ErrHeld {
+			log.Printf("[%s] %s由其他实例抓取，跳过", mr.Name(), date.Format(datePattern))
+			continue
+		}
+		if err != nil {
+			return err
 		}
+	}
 
-		// 后移一天
-		date = date.AddDate(0, 0, 1)
+	if len(uncovered) > 0 {
+		return fmt.Errorf("[%s] 交易日历未包含%s至%s的不规则节假日，这%d天没有记录，请在交易日历中补充", mr.Name(), uncovered[0], uncovered[len(uncovered)-1], len(uncovered))
 	}
 
 	return nil
 }
 
-// crawlYesterdayData 每天0点抓取前一天的数据
-func (mr marketRecorder) crawlYesterdayData(yesterday time.Time) error {
+// crawlDay 抓取某个交易日的数据，已存在的不再抓取，交易日历未覆盖时返回错误(记录到状态并发送通知)
+func (mr marketRecorder) crawlDay(ctx context.Context, date time.Time) error {
 
-	// 避免重复记录
-	recorded, err := mr.store.Exists(mr.Market, yesterday)
-	if err != nil || recorded {
+	err := mr.checkCoverage(date)
+	if err != nil {
 		return err
 	}
 
-	// 获取上市公司
-	companies, err := mr.Market.Companies()
-	if err != nil {
-		return err
+	// 跳过非交易日
+	if !mr.isTradingDay(date) {
+		return nil
 	}
-	log.Printf("[%s] 共有%d家上市公司", mr.Name(), len(companies))
 
-	// 抓取
-	return mr.crawl(companies, yesterday)
+	// 多实例时等待其他实例完成，其他实例崩溃时接手
+	return mr.withLease(ctx, date, true, func(ctx context.Context) error {
+
+		// 避免重复记录
+		recorded, err := mr.store.Exists(ctx, mr.Market, date)
+		if err != nil || recorded {
+			return err
+		}
+
+		// 获取上市公司
+		companies, err := mr.companies(ctx, date)
+		if err != nil {
+			return err
+		}
+		log.Printf("[%s] 共有%d家上市公司", mr.Name(), len(companies))
+		companies = mr.filterCompanies(ctx, date, companies)
+
+		// 抓取
+		return mr.crawl(ctx, companies, date, nil)
+	})
 }
 
-// crawl 抓取指定日期的市场报价
-func (mr marketRecorder) crawl(companies []market.Company, date time.Time) error {
+// checkCoverage 交易日历是否覆盖了date所在年份，农历节日等不规则节假日无法按规则推算，按普通工作日记录会把休市日当作交易日
+func (mr marketRecorder) checkCoverage(date time.Time) error {
 
-	ch := make(chan bool, mr.source.ParallelMax())
-	defer close(ch)
+	if mr.Market.Calendar().Covers(date.Year()) {
+		return nil
+	}
 
-	var wg sync.WaitGroup
-	wg.Add(len(companies))
+	return fmt.Errorf("[%s] 交易日历未包含%d年的不规则节假日，无法记录%s，请在交易日历中补充", mr.Name(), date.Year(), date.Format(datePattern))
+}
+
+// isTradingDay 是否为交易日，交易日历未覆盖的年份不作为交易日
+func (mr marketRecorder) isTradingDay(date time.Time) bool {
+
+	c := mr.Market.Calendar()
+	if err := mr.checkCoverage(date); err != nil {
+		log.Print(err)
+		return false
+	}
+
+	if c.IsTradingDay(date) {
+		return true
+	}
+
+	if name, found := c.Holiday(date); found {
+		log.Printf("[%s] %s为%s，休市", mr.Name(), date.Format(datePattern), name)
+	}
+
+	return false
+}
+
+// crawl 抓取指定日期的市场报价，base为当天已有的报价，抓取结果会覆盖其中的同名公司后一起保存
+func (mr marketRecorder) crawl(ctx context.Context, companies []market.Company, date time.Time, base []market.CompanyDailyQuote) (err error) {
 
 	_, offset := date.Zone()
 
@@ -186,31 +399,220 @@ func (mr marketRecorder) crawl(companies []market.Company, date time.Time) error
 		UTCOffset: offset,
 	}
 
-	for _, company := range companies {
+	summary := newCrawlSummary(mr.Market, date, len(companies))
+	queue := newFailureQueue()
 
-		go func(_market market.Market, _company market.Company, _date time.Time) {
-			quote, err := mr.source.Crawl(_market, _company, _date)
-			if err == nil {
-				dailyQuote.Quotes = append(dailyQuote.Quotes, *quote)
+	// 无论成功与否都追加一条抓取记录
+	start, bytes := time.Now(), 0
+	defer func() { mr.record(ctx, summary, start, bytes, err) }()
+
+	// 从检查点恢复已抓取的公司
+	cp, done, err := openCheckpoint(mr.config.CheckpointDir, mr.Market, date)
+	if err != nil {
+		return fmt.Errorf("[%s] 打开%s的检查点时发生错误: %v", mr.Market.Name(), date.Format(datePattern), err)
+	}
+	defer cp.Close()
+
+	pending := companies
+	if len(done) > 0 {
+		dailyQuote.Quotes, pending = mr.resume(companies, done)
+		log.Printf("[%s] 从检查点恢复了%s的%d家上市公司，还需抓取%d家", mr.Market.Name(), date.Format(datePattern), len(dailyQuote.Quotes), len(pending))
+	}
+
+	mr.status.StartCrawl(date, len(dailyQuote.Quotes), len(companies))
+	saved := false
+	defer func() { mr.status.FinishCrawl(date, saved) }()
+	for pass := 0; pass <= failureRetryPasses; pass++ {
+
+		if pass > 0 {
+			// 失败的公司延时后重试，每轮延时翻倍
+			interval := failureRetryInterval * time.Duration(1<<uint(pass-1))
+			log.Printf("[%s] %s有%d家上市公司抓取失败，将于%s后进行第%d轮重试", mr.Market.Name(), date.Format(datePattern), queue.Len(), interval.String(), pass)
+			select {
+			case <-ctx.Done():
+			case <-time.After(interval):
 			}
+		}
 
-			<-ch
-			wg.Done()
-		}(mr.Market, company, date)
+		quotes, errs := mr.crawlCompanies(ctx, pending, date, cp)
+		dailyQuote.Quotes = append(dailyQuote.Quotes, quotes...)
+		summary.Passes++
+
+		for _, quote := range quotes {
+			queue.Remove(quote.Company)
+			if quote.Source != "" {
+				summary.Sources[quote.Source]++
+			}
+		}
+
+		for _, company := range pending {
+			if err, found := errs[company.Code]; found {
+				queue.Add(company, date, err)
+			}
+		}
+
+		// 已取消的不保存，避免把不完整的一天记录为已存在，已抓取的公司保留在检查点中
+		if ctx.Err() != nil {
+			if cp != nil {
+				log.Printf("[%s] %s的抓取已取消，已抓取的%d家上市公司保存在检查点中，重启后继续", mr.Market.Name(), date.Format(datePattern), len(dailyQuote.Quotes))
+			} else {
+				log.Printf("[%s] %s的抓取已取消，丢弃已抓取的%d家上市公司", mr.Market.Name(), date.Format(datePattern), len(dailyQuote.Quotes))
+			}
+			summary.Succeeded, summary.Failures = len(dailyQuote.Quotes), queue.Failures()
+			return ctx.Err()
+		}
 
-		// 限流
-		ch <- false
+		if queue.Len() == 0 {
+			break
+		}
+
+		pending = queue.Companies()
 	}
-	//	阻塞，直到抓取所有
-	wg.Wait()
 
-	// 保存
-	err := mr.store.Save(dailyQuote)
+	summary.Succeeded = len(dailyQuote.Quotes)
+	crawled := dailyQuote.Quotes
+	dailyQuote.Quotes = mergeQuotes(base, dailyQuote.Quotes)
+	summary.Failures = queue.Failures()
+	summary.Log()
+
+	// 校验数据质量
+	var report quality.Report
+	dailyQuote.Quotes, report = quality.Validate(mr.config.Validation, mr.Market, date, dailyQuote.Quotes)
+	log.Printf("[%s] %s的数据质量: %s", mr.Market.Name(), date.Format(datePattern), report.String())
+	for kind, count := range report.Counts {
+		qualityIssues.Add(float64(count), mr.Market.Name(), kind)
+	}
+
+	// 保存，已抓取完成的一天即使此时收到取消也要保存下来
+	bytes, err = mr.store.Save(context.WithoutCancel(ctx), dailyQuote)
 	if err != nil {
 		return fmt.Errorf("[%s] 保存上市公司在%s的分时数据时发生错误: %v", mr.Market.Name(), date.Format(datePattern), err)
 	}
 
+	saved = true
+
+	// 质量报告与当天数据一起保存
+	if buffer, err := report.Marshal(); err == nil {
+		err = mr.store.SaveAttachment(context.WithoutCancel(ctx), mr.Market, date, quality.ReportName, buffer)
+		if err != nil {
+			log.Printf("[%s] 保存%s的数据质量报告时发生错误: %v", mr.Market.Name(), date.Format(datePattern), err)
+		}
+	}
+
+	// 分红、拆股等公司事件单独保存，base中的公司没有重新抓取，保留其已保存的事件
+	mr.saveEvents(context.WithoutCancel(ctx), date, crawled)
+
+	companiesCrawled.Add(float64(summary.Succeeded), mr.Market.Name())
+	companiesFailed.Add(float64(summary.Failed()), mr.Market.Name())
+	daysRecorded.Inc(mr.Market.Name())
+	lastRecordedDate.Set(float64(date.Unix()), mr.Market.Name())
+	lastSuccess.Set(float64(time.Now().Unix()), mr.Market.Name())
+
+	mr.notifyCompleted(ctx, summary, report)
+
+	// 已保存，检查点不再需要
+	err = cp.Remove()
+	if err != nil {
+		log.Printf("[%s] 删除%s的检查点时发生错误: %v", mr.Market.Name(), date.Format(datePattern), err)
+	}
+
 	log.Printf("[%s] 上市公司在%s的分时数据已经抓取结束", mr.Market.Name(), date.Format(datePattern))
 
 	return nil
 }
+
+// mergeQuotes 合并报价，quotes覆盖base中的同名公司，结果按Code排序
+func mergeQuotes(base, quotes []market.CompanyDailyQuote) []market.CompanyDailyQuote {
+
+	dict := make(map[string]market.CompanyDailyQuote, len(base)+len(quotes))
+	for _, quote := range base {
+		dict[quote.Code] = quote
+	}
+
+	for _, quote := range quotes {
+		dict[quote.Code] = quote
+	}
+
+	merged := make([]market.CompanyDailyQuote, 0, len(dict))
+	for _, quote := range dict {
+		merged = append(merged, quote)
+	}
+
+	//	按Code排序，保证多轮抓取后的顺序一致
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Code < merged[j].Code
+	})
+
+	return merged
+}
+
+// resume 从检查点恢复，返回检查点中属于companies的报价和仍需抓取的公司
+func (mr marketRecorder) resume(companies []market.Company, done []market.CompanyDailyQuote) ([]market.CompanyDailyQuote, []market.Company) {
+
+	dict := make(map[string]market.CompanyDailyQuote, len(done))
+	for _, quote := range done {
+		dict[quote.Code] = quote
+	}
+
+	var quotes []market.CompanyDailyQuote
+	var pending []market.Company
+	for _, company := range companies {
+		if quote, found := dict[company.Code]; found {
+			quotes = append(quotes, quote)
+			continue
+		}
+
+		pending = append(pending, company)
+	}
+
+	return quotes, pending
+}
+
+// crawlCompanies 并发抓取一批公司的报价，成功的同时写入检查点，返回成功的报价和失败的错误(按Code索引)
+func (mr marketRecorder) crawlCompanies(ctx context.Context, companies []market.Company, date time.Time, cp *checkpoint) ([]market.CompanyDailyQuote, map[string]error) {
+
+	ch := make(chan bool, mr.source.ParallelMax())
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var quotes []market.CompanyDailyQuote
+	errs := make(map[string]error)
+
+dispatch:
+	for _, company := range companies {
+
+		// 限流，取消后不再发起新的抓取
+		select {
+		case ch <- false:
+		case <-ctx.Done():
+			break dispatch
+		}
+
+		wg.Add(1)
+		go func(_market market.Market, _company market.Company, _date time.Time) {
+			quote, err := mr.source.Crawl(ctx, _market, _company, _date)
+
+			if err == nil {
+				if err := cp.Append(*quote); err != nil {
+					log.Printf("[%s] %v", _market.Name(), err)
+				}
+			}
+
+			mutex.Lock()
+			if err == nil {
+				quotes = append(quotes, *quote)
+				mr.status.CompanyDone()
+			} else {
+				errs[_company.Code] = err
+			}
+			mutex.Unlock()
+
+			<-ch
+			wg.Done()
+		}(mr.Market, company, date)
+	}
+	//	阻塞，直到进行中的抓取都已结束
+	wg.Wait()
+
+	return quotes, errs
+}
@@ -0,0 +1,273 @@
+package recorder
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nzai/stockrecorder/calendar"
+)
+
+const (
+	// defaultSchedule 默认每天0点执行
+	defaultSchedule = "0 0 * * *"
+	// scheduleSearchDays 查找下一次/上一次执行时间的最大天数
+	scheduleSearchDays = 366
+)
+
+var (
+	// regexSessionSchedule 相对开收市时间的定时，如 close+45m
+	regexSessionSchedule = regexp.MustCompile(`^(open|close)\s*(?:\+\s*(\S+))?$`)
+)
+
+// schedule 定时任务
+type schedule interface {
+	// 晚于after的下一次执行时间
+	Next(after time.Time) time.Time
+	// 不晚于before的上一次执行时间
+	Prev(before time.Time) time.Time
+}
+
+// parseSchedules 解析市场的定时任务，没有配置时默认每天0点执行
+func parseSchedules(specs []string, c *calendar.Calendar) ([]schedule, error) {
+
+	if len(specs) == 0 {
+		specs = []string{defaultSchedule}
+	}
+
+	var schedules []schedule
+	for _, spec := range specs {
+
+		s, err := parseSchedule(spec, c)
+		if err != nil {
+			return nil, err
+		}
+
+		schedules = append(schedules, s)
+	}
+
+	return schedules, nil
+}
+
+// parseSchedule 解析定时任务，支持 close+45m 这样相对开收市时间的写法及5段式cron表达式
+func parseSchedule(spec string, c *calendar.Calendar) (schedule, error) {
+
+	text := strings.ToLower(strings.TrimSpace(spec))
+
+	matches := regexSessionSchedule.FindStringSubmatch(text)
+	if len(matches) == 3 {
+		var offset time.Duration
+		if matches[2] != "" {
+			var err error
+			offset, err = time.ParseDuration(matches[2])
+			if err != nil || offset < 0 {
+				return nil, fmt.Errorf("错误的定时任务:%s", spec)
+			}
+		}
+
+		return sessionSchedule{calendar: c, open: matches[1] == "open", offset: offset}, nil
+	}
+
+	cron, err := parseCron(text)
+	if err != nil {
+		return nil, fmt.Errorf("错误的定时任务:%s %v", spec, err)
+	}
+
+	return cron, nil
+}
+
+// sessionSchedule 每个交易日相对开市或收市时间的定时
+type sessionSchedule struct {
+	calendar *calendar.Calendar
+	open     bool          // 相对开市时间，否则相对收市时间
+	offset   time.Duration // 偏移
+}
+
+// at 某个交易日的执行时间
+func (s sessionSchedule) at(date time.Time) time.Time {
+
+	if s.open {
+		return s.calendar.Open(date).Add(s.offset)
+	}
+
+	return s.calendar.Close(date).Add(s.offset)
+}
+
+// Next 晚于after的下一次执行时间
+func (s sessionSchedule) Next(after time.Time) time.Time {
+
+	// 偏移可能超过一天，从前一天开始找
+	date := after.AddDate(0, 0, -1)
+	for index := 0; index < scheduleSearchDays; index++ {
+
+		if s.calendar.IsTradingDay(date) {
+			if at := s.at(date); at.After(after) {
+				return at
+			}
+		}
+
+		date = date.AddDate(0, 0, 1)
+	}
+
+	return time.Time{}
+}
+
+// Prev 不晚于before的上一次执行时间
+func (s sessionSchedule) Prev(before time.Time) time.Time {
+
+	date := before
+	for index := 0; index < scheduleSearchDays; index++ {
+
+		if s.calendar.IsTradingDay(date) {
+			if at := s.at(date); !at.After(before) {
+				return at
+			}
+		}
+
+		date = date.AddDate(0, 0, -1)
+	}
+
+	return time.Time{}
+}
+
+// cronSchedule 5段式cron表达式: 分 时 日 月 星期
+type cronSchedule struct {
+	minutes, hours, days, months, weekdays map[int]bool
+	anyDay, anyWeekday                     bool
+}
+
+// parseCron 解析cron表达式
+func parseCron(text string) (cronSchedule, error) {
+
+	var s cronSchedule
+
+	fields := strings.Fields(text)
+	if len(fields) != 5 {
+		return s, fmt.Errorf("cron表达式需要5段")
+	}
+
+	var err error
+	if s.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+		return s, err
+	}
+
+	if s.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+		return s, err
+	}
+
+	if s.days, err = parseCronField(fields[2], 1, 31); err != nil {
+		return s, err
+	}
+
+	if s.months, err = parseCronField(fields[3], 1, 12); err != nil {
+		return s, err
+	}
+
+	if s.weekdays, err = parseCronField(fields[4], 0, 7); err != nil {
+		return s, err
+	}
+
+	// 星期日可以写作0或7
+	if s.weekdays[7] {
+		s.weekdays[0] = true
+	}
+
+	s.anyDay, s.anyWeekday = fields[2] == "*", fields[4] == "*"
+
+	return s, nil
+}
+
+// parseCronField 解析cron表达式的一段，支持 * 、数字、a-b 、列表及 /n 步长
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+
+		step := 1
+		if index := strings.Index(part, "/"); index >= 0 {
+			n, err := strconv.Atoi(part[index+1:])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("错误的步长:%s", part)
+			}
+			step, part = n, part[:index]
+		}
+
+		from, to := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+
+			var err error
+			from, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("错误的数值:%s", part)
+			}
+
+			to = from
+			if len(bounds) == 2 {
+				to, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, fmt.Errorf("错误的数值:%s", part)
+				}
+			}
+		}
+
+		if from < min || to > max || from > to {
+			return nil, fmt.Errorf("超出范围[%d-%d]:%s", min, max, part)
+		}
+
+		for value := from; value <= to; value += step {
+			values[value] = true
+		}
+	}
+
+	return values, nil
+}
+
+// match 某分钟是否满足表达式
+func (s cronSchedule) match(t time.Time) bool {
+
+	if !s.minutes[t.Minute()] || !s.hours[t.Hour()] || !s.months[int(t.Month())] {
+		return false
+	}
+
+	// 日和星期都有限定时满足其一即可
+	day, weekday := s.days[t.Day()], s.weekdays[int(t.Weekday())]
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekday
+	case s.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+// Next 晚于after的下一次执行时间
+func (s cronSchedule) Next(after time.Time) time.Time {
+
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	for end := after.AddDate(0, 0, scheduleSearchDays); t.Before(end); t = t.Add(time.Minute) {
+		if s.match(t) {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// Prev 不晚于before的上一次执行时间
+func (s cronSchedule) Prev(before time.Time) time.Time {
+
+	t := before.Truncate(time.Minute)
+	for end := before.AddDate(0, 0, -scheduleSearchDays); t.After(end); t = t.Add(-time.Minute) {
+		if s.match(t) {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
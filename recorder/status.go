@@ -0,0 +1,101 @@
+package recorder
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MarketStatus 市场记录器状态
+type MarketStatus struct {
+	Market      string    `json:"market"`                 // 市场
+	NextRun     time.Time `json:"next_run"`               // 下一次定时任务
+	History     bool      `json:"history"`                // 是否正在获取历史数据
+	Crawling    string    `json:"crawling,omitempty"`     // 正在抓取的日期
+	Done        int       `json:"done"`                   // 正在抓取的日期已完成的公司数
+	Remaining   int       `json:"remaining"`              // 正在抓取的日期还未完成的公司数
+	LastSuccess string    `json:"last_success,omitempty"` // 最近一次保存的日期
+	LastError   string    `json:"last_error,omitempty"`   // 最近一次错误
+	LastErrorAt time.Time `json:"last_error_at"`          // 最近一次错误的时间
+}
+
+// status 可并发更新的市场记录器状态
+type status struct {
+	mutex sync.Mutex
+	MarketStatus
+}
+
+// newStatus 新建市场记录器状态
+func newStatus(name string) *status {
+	return &status{MarketStatus: MarketStatus{Market: name}}
+}
+
+// update 加锁更新
+func (s *status) update(action func(ms *MarketStatus)) {
+	s.mutex.Lock()
+	action(&s.MarketStatus)
+	s.mutex.Unlock()
+}
+
+// snapshot 当前状态的副本
+func (s *status) snapshot() MarketStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.MarketStatus
+}
+
+// SetNextRun 设置下一次定时任务
+func (s *status) SetNextRun(next time.Time) {
+	s.update(func(ms *MarketStatus) { ms.NextRun = next })
+}
+
+// SetHistory 设置是否正在获取历史数据
+func (s *status) SetHistory(history bool) {
+	s.update(func(ms *MarketStatus) { ms.History = history })
+}
+
+// StartCrawl 开始抓取某天
+func (s *status) StartCrawl(date time.Time, done, total int) {
+	s.update(func(ms *MarketStatus) {
+		ms.Crawling, ms.Done, ms.Remaining = date.Format(datePattern), done, total-done
+	})
+}
+
+// CompanyDone 完成一家公司
+func (s *status) CompanyDone() {
+	s.update(func(ms *MarketStatus) {
+		ms.Done++
+		ms.Remaining--
+	})
+}
+
+// FinishCrawl 结束抓取某天，saved表示已保存
+func (s *status) FinishCrawl(date time.Time, saved bool) {
+	s.update(func(ms *MarketStatus) {
+		ms.Crawling, ms.Done, ms.Remaining = "", 0, 0
+		if saved {
+			ms.LastSuccess = date.Format(datePattern)
+		}
+	})
+}
+
+// SetError 记录错误
+func (s *status) SetError(err error) {
+	s.update(func(ms *MarketStatus) { ms.LastError, ms.LastErrorAt = err.Error(), time.Now() })
+}
+
+// Status 各市场记录器的当前状态
+func (r Recorder) Status() []MarketStatus {
+
+	statuses := make([]MarketStatus, 0, len(r.statuses))
+	for _, s := range r.statuses {
+		statuses = append(statuses, s.snapshot())
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Market < statuses[j].Market
+	})
+
+	return statuses
+}
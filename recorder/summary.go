@@ -0,0 +1,185 @@
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/nzai/stockrecorder/market"
+	"github.com/nzai/stockrecorder/notify"
+	"github.com/nzai/stockrecorder/quality"
+)
+
+const (
+	// failureRetryPasses 失败公司的最大重试轮数
+	failureRetryPasses = 3
+	// failureRetryInterval 第一轮重试前的等待时间，之后每轮翻倍
+	failureRetryInterval = time.Minute
+)
+
+// Failure 公司抓取失败记录
+type Failure struct {
+	Company  market.Company // 公司
+	Date     time.Time      // 日期
+	Err      error          // 最后一次错误
+	Attempts int            // 已尝试次数
+}
+
+// String 显示
+func (f Failure) String() string {
+	return fmt.Sprintf("%s(%s) 尝试%d次 最后一次错误: %v", f.Company.Code, f.Company.Name, f.Attempts, f.Err)
+}
+
+// CrawlSummary 每日抓取汇总
+type CrawlSummary struct {
+	Market    string         // 市场
+	Date      time.Time      // 日期
+	Total     int            // 公司总数
+	Succeeded int            // 成功数
+	Passes    int            // 抓取轮数(含首轮)
+	Failures  []Failure      // 最终仍然失败的公司
+	Sources   map[string]int // 各数据源抓取成功的公司数(不含从检查点恢复的)
+	Repair    bool           // 是否为校验后重新抓取部分公司
+}
+
+// newCrawlSummary 新建每日抓取汇总
+func newCrawlSummary(_market market.Market, date time.Time, total int) *CrawlSummary {
+	return &CrawlSummary{Market: _market.Name(), Date: date, Total: total, Sources: make(map[string]int)}
+}
+
+// Failed 失败数
+func (s CrawlSummary) Failed() int {
+	return len(s.Failures)
+}
+
+// FailureRate 失败率
+func (s CrawlSummary) FailureRate() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+
+	return float64(len(s.Failures)) / float64(s.Total)
+}
+
+// Complete 是否全部抓取成功
+func (s CrawlSummary) Complete() bool {
+	return len(s.Failures) == 0
+}
+
+// Log 输出汇总
+func (s CrawlSummary) Log() {
+
+	log.Printf("[%s] %s的抓取汇总: 共%d家上市公司, 成功%d家, 失败%d家, 共抓取%d轮",
+		s.Market, s.Date.Format(datePattern), s.Total, s.Succeeded, s.Failed(), s.Passes)
+
+	if len(s.Sources) > 0 {
+		names := make([]string, 0, len(s.Sources))
+		for name := range s.Sources {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			log.Printf("[%s] %s数据源%s: %d家", s.Market, s.Date.Format(datePattern), name, s.Sources[name])
+		}
+	}
+
+	for _, failure := range s.Failures {
+		log.Printf("[%s] %s抓取失败: %s", s.Market, s.Date.Format(datePattern), failure)
+	}
+}
+
+// failureQueue 失败重试队列
+type failureQueue struct {
+	failures map[string]*Failure
+}
+
+// newFailureQueue 新建失败重试队列
+func newFailureQueue() *failureQueue {
+	return &failureQueue{failures: make(map[string]*Failure)}
+}
+
+// Add 记录一次失败
+func (q *failureQueue) Add(company market.Company, date time.Time, err error) {
+
+	failure, found := q.failures[company.Code]
+	if !found {
+		failure = &Failure{Company: company, Date: date}
+		q.failures[company.Code] = failure
+	}
+
+	failure.Err = err
+	failure.Attempts++
+}
+
+// Remove 重试成功后移出队列
+func (q *failureQueue) Remove(company market.Company) {
+	delete(q.failures, company.Code)
+}
+
+// Len 队列长度
+func (q failureQueue) Len() int {
+	return len(q.failures)
+}
+
+// Companies 需要重试的公司
+func (q failureQueue) Companies() []market.Company {
+
+	companies := make([]market.Company, 0, len(q.failures))
+	for _, failure := range q.failures {
+		companies = append(companies, failure.Company)
+	}
+
+	//	按Code排序
+	sort.Sort(market.CompanyList(companies))
+
+	return companies
+}
+
+// Failures 仍然失败的公司
+func (q failureQueue) Failures() []Failure {
+
+	failures := make([]Failure, 0, len(q.failures))
+	for _, failure := range q.failures {
+		failures = append(failures, *failure)
+	}
+
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].Company.Code < failures[j].Company.Code
+	})
+
+	return failures
+}
+
+// notifyCompleted 当天已保存，发送完成通知，失败率过高时另外告警
+func (mr marketRecorder) notifyCompleted(ctx context.Context, summary *CrawlSummary, report quality.Report) {
+
+	event := notify.NewEvent(notify.DayCompleted, summary.Market, summary.Date, "共%d家上市公司, 成功%d家, 失败%d家", summary.Total, summary.Succeeded, summary.Failed())
+	event.Details = map[string]interface{}{
+		"total":     summary.Total,
+		"succeeded": summary.Succeeded,
+		"failed":    summary.Failed(),
+		"passes":    summary.Passes,
+		"sources":   summary.Sources,
+		"quality":   report.Counts,
+	}
+	mr.notifier.Notify(ctx, event)
+
+	if summary.Failed() == 0 || summary.FailureRate() < mr.notifier.FailureRate() {
+		return
+	}
+
+	failures := make([]string, 0, len(summary.Failures))
+	for _, failure := range summary.Failures {
+		failures = append(failures, failure.String())
+	}
+
+	event = notify.NewEvent(notify.HighFailureRate, summary.Market, summary.Date, "失败率%.1f%%，共%d家上市公司抓取失败", summary.FailureRate()*100, summary.Failed())
+	event.Details = map[string]interface{}{
+		"rate":     summary.FailureRate(),
+		"failures": failures,
+	}
+	mr.notifier.Notify(ctx, event)
+}
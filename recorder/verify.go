@@ -0,0 +1,391 @@
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/nzai/stockrecorder/calendar"
+	"github.com/nzai/stockrecorder/lease"
+	"github.com/nzai/stockrecorder/market"
+	"github.com/nzai/stockrecorder/quality"
+)
+
+const (
+	// defaultMinBarRatio 盘中报价数低于该公司近期水平的比例时视为不完整
+	defaultMinBarRatio = 0.5
+	// verifyHistoryDays 比较盘中报价数时参考的近期交易日数
+	verifyHistoryDays = 5
+)
+
+// VerifyOptions 校验选项
+type VerifyOptions struct {
+	Start       time.Time // 起始日期(含)
+	End         time.Time // 结束日期(含)
+	MinBarRatio float64   // 盘中报价数低于该公司近期(前5个有数据的交易日)水平的此比例时视为不完整，为0时使用默认值0.5，为负数时不检查
+	Repair      bool      // 重新抓取缺失及不完整的公司(只限数据源有效期内的日期)
+}
+
+// minBarRatio 不完整的比例
+func (o VerifyOptions) minBarRatio() float64 {
+
+	if o.MinBarRatio == 0 {
+		return defaultMinBarRatio
+	}
+
+	return o.MinBarRatio
+}
+
+// DayVerification 某天的校验结果
+type DayVerification struct {
+	Date      time.Time        // 日期
+	Recorded  bool             // 是否有当天的数据
+	Snapshot  bool             // 是否有当天的上市公司快照
+	Companies int              // 当天数据中的公司数
+	Expected  int              // 盘中时段的分钟数，即每分钟都有成交时的报价数
+	Missing   []market.Company // 快照中有但当天数据中没有的公司
+	Truncated []market.Company // 盘中报价数过少的公司
+	Repaired  bool             // 是否已重新抓取
+}
+
+// OK 是否完整
+func (v DayVerification) OK() bool {
+	return v.Recorded && len(v.Missing) == 0 && len(v.Truncated) == 0
+}
+
+// String 摘要
+func (v DayVerification) String() string {
+
+	if !v.Recorded {
+		return fmt.Sprintf("%s 没有数据", v.Date.Format(datePattern))
+	}
+
+	snapshot := "有快照"
+	if !v.Snapshot {
+		snapshot = "无快照"
+	}
+
+	text := fmt.Sprintf("%s %d家公司(%s) 缺失%d家 不完整%d家", v.Date.Format(datePattern), v.Companies, snapshot, len(v.Missing), len(v.Truncated))
+	if v.Repaired {
+		text += " 已重新抓取"
+	}
+
+	return text
+}
+
+// Verify 校验某市场一段时间已保存的数据，按需重新抓取缺失及不完整的公司
+func (r Recorder) Verify(ctx context.Context, _market market.Market, options VerifyOptions) ([]DayVerification, error) {
+	return r.marketRecorder(_market).verify(ctx, options)
+}
+
+// verify 校验一段时间已保存的数据
+func (mr marketRecorder) verify(ctx context.Context, options VerifyOptions) ([]DayVerification, error) {
+
+	//	日期按市场所在时区计算
+	location := mr.marketNow().Location()
+	start := time.Date(options.Start.Year(), options.Start.Month(), options.Start.Day(), 0, 0, 0, 0, location)
+	end := time.Date(options.End.Year(), options.End.Month(), options.End.Day(), 0, 0, 0, 0, location)
+
+	if end.Before(start) {
+		return nil, fmt.Errorf("[%s] 校验的结束日期%s早于起始日期%s", mr.Name(), end.Format(datePattern), start.Format(datePattern))
+	}
+
+	expiration := mr.marketNow().Add(-mr.source.Expiration())
+
+	// 成交稀少的公司本来就没有每分钟的报价，与各公司自己近期的报价数比较
+	history := make(barHistory)
+	if options.minBarRatio() > 0 {
+		var err error
+		history, err = mr.barHistory(ctx, start)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var verifications []DayVerification
+	for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
+
+		if ctx.Err() != nil {
+			return verifications, ctx.Err()
+		}
+
+		// 跳过非交易日
+		if !mr.isTradingDay(date) {
+			continue
+		}
+
+		v, quotes, err := mr.verifyDay(ctx, date, options, history)
+		if err != nil {
+			return verifications, err
+		}
+
+		if options.Repair && !v.OK() {
+			if date.Before(expiration) {
+				log.Printf("[%s] %s超出了数据源的有效期%s，无法重新抓取", mr.Name(), date.Format(datePattern), mr.source.Expiration().String())
+			} else {
+				err = mr.repairDay(ctx, date, v, options, history)
+				if err != nil {
+					return verifications, err
+				}
+				v.Repaired = true
+			}
+		}
+
+		log.Printf("[%s] %s", mr.Name(), v.String())
+		verifications = append(verifications, v)
+
+		history.add(quotes, v.Expected)
+	}
+
+	return verifications, nil
+}
+
+// verifyDay 校验某天的数据，同时返回当天已保存的报价，history为各公司近期的盘中报价水平
+func (mr marketRecorder) verifyDay(ctx context.Context, date time.Time, options VerifyOptions, history barHistory) (DayVerification, []market.CompanyDailyQuote, error) {
+
+	v := DayVerification{Date: date, Expected: mr.regularMinutes(date)}
+
+	exists, err := mr.store.Exists(ctx, mr.Market, date)
+	if err != nil {
+		return v, nil, err
+	}
+
+	var quotes []market.CompanyDailyQuote
+	if exists {
+		dailyQuote, err := mr.store.Load(ctx, mr.Market, date)
+		if err != nil {
+			return v, nil, fmt.Errorf("[%s] 读取%s的数据时发生错误: %v", mr.Name(), date.Format(datePattern), err)
+		}
+		quotes = dailyQuote.Quotes
+		v.Recorded, v.Companies = true, len(quotes)
+	}
+
+	// 与当天的上市公司快照(按筛选条件)比较
+	snapshot, err := mr.store.LoadCompanies(ctx, mr.Market, date)
+	if err == nil {
+		v.Snapshot = true
+		snapshot = mr.filterCompanies(ctx, date, snapshot)
+
+		recorded := make(map[string]bool, len(quotes))
+		for _, quote := range quotes {
+			recorded[quote.Code] = true
+		}
+
+		for _, company := range snapshot {
+			if !recorded[company.Code] {
+				v.Missing = append(v.Missing, company)
+			}
+		}
+	}
+
+	// 盘中报价数比该公司近期水平少得多
+	for _, quote := range quotes {
+		if history.truncated(quote, v.Expected, options.minBarRatio()) {
+			v.Truncated = append(v.Truncated, quote.Company)
+		}
+	}
+
+	return v, quotes, nil
+}
+
+// repairDay 重新抓取某天缺失及不完整的公司，当天已有数据时按公司保存，不改动其他公司
+func (mr marketRecorder) repairDay(ctx context.Context, date time.Time, v DayVerification, options VerifyOptions, history barHistory) error {
+
+	companies := append(append([]market.Company(nil), v.Missing...), v.Truncated...)
+
+	// 当天没有数据也没有快照时抓取当前的全部上市公司
+	if !v.Recorded && len(companies) == 0 {
+		var err error
+		companies, err = mr.companies(ctx, mr.today())
+		if err != nil {
+			return err
+		}
+		companies = mr.filterCompanies(ctx, date, companies)
+	}
+
+	if len(companies) == 0 {
+		return nil
+	}
+
+	log.Printf("[%s] 重新抓取%s的%d家上市公司", mr.Name(), date.Format(datePattern), len(companies))
+
+	err := mr.withLease(ctx, date, false, func(ctx context.Context) error {
+
+		// 当天没有数据时整体抓取保存
+		if !v.Recorded {
+			return mr.crawl(ctx, companies, date, nil)
+		}
+
+		return mr.repairCompanies(ctx, companies, date, v.Expected, options.minBarRatio(), history)
+	})
+	if err == lease.ErrHeld {
+		return fmt.Errorf("[%s] %s正在由其他实例抓取，请稍后重试", mr.Name(), date.Format(datePattern))
+	}
+
+	return err
+}
+
+// repairCompanies 重新抓取一批公司并逐个保存，覆盖当天数据中的同一家公司，重新抓取的盘中报价不比已保存的多时不覆盖
+func (mr marketRecorder) repairCompanies(ctx context.Context, companies []market.Company, date time.Time, expected int, ratio float64, history barHistory) (err error) {
+
+	summary := newCrawlSummary(mr.Market, date, len(companies))
+	summary.Repair = true
+	queue := newFailureQueue()
+
+	// 重新抓取也追加一条抓取记录
+	start, bytes := time.Now(), 0
+	defer func() { mr.record(ctx, summary, start, bytes, err) }()
+
+	quotes, errs := mr.crawlCompanies(ctx, companies, date, nil)
+	summary.Passes++
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	for _, company := range companies {
+		if err, found := errs[company.Code]; found {
+			log.Printf("[%s] 重新抓取%s在%s的报价时发生错误: %v", mr.Name(), company.Code, date.Format(datePattern), err)
+			queue.Add(company, date, err)
+		}
+	}
+
+	quotes, report := quality.Validate(mr.config.Validation, mr.Market, date, quotes)
+	log.Printf("[%s] %s重新抓取的%d家上市公司的数据质量: %s", mr.Name(), date.Format(datePattern), len(quotes), report.String())
+
+	var saved []market.CompanyDailyQuote
+	for _, quote := range quotes {
+
+		existing, found, err := mr.loadCompany(ctx, date, quote.Code)
+		if err != nil {
+			return fmt.Errorf("[%s] 读取%s在%s的报价时发生错误: %v", mr.Name(), quote.Code, date.Format(datePattern), err)
+		}
+
+		// 数据源当天的数据可能本来就不完整，重新抓取的不比已保存的多时保留原有报价
+		if found && quote.Regular.Count <= existing.Regular.Count {
+			log.Printf("[%s] %s在%s重新抓取的盘中报价数%d不多于已保存的%d，不覆盖", mr.Name(), quote.Code, date.Format(datePattern), quote.Regular.Count, existing.Regular.Count)
+			queue.Add(quote.Company, date, fmt.Errorf("重新抓取的盘中报价数%d不多于已保存的%d", quote.Regular.Count, existing.Regular.Count))
+			continue
+		}
+
+		if history.truncated(quote, expected, ratio) {
+			log.Printf("[%s] %s在%s重新抓取的盘中报价数%d仍然过少，但比原有的多，仍然保存", mr.Name(), quote.Code, date.Format(datePattern), quote.Regular.Count)
+		}
+
+		size, err := mr.store.SaveCompany(context.WithoutCancel(ctx), mr.Market, date, quote)
+		if err != nil {
+			return fmt.Errorf("[%s] 保存%s在%s的报价时发生错误: %v", mr.Name(), quote.Code, date.Format(datePattern), err)
+		}
+
+		saved, bytes = append(saved, quote), bytes+size
+		if quote.Source != "" {
+			summary.Sources[quote.Source]++
+		}
+	}
+
+	summary.Succeeded, summary.Failures = len(saved), queue.Failures()
+	summary.Log()
+
+	mr.saveEvents(context.WithoutCancel(ctx), date, saved)
+
+	return nil
+}
+
+// barHistory 各公司近期每天的盘中报价数与盘中分钟数之比，按Code索引
+type barHistory map[string][]float64
+
+// add 加入一天的报价，只保留最近的verifyHistoryDays天
+func (h barHistory) add(quotes []market.CompanyDailyQuote, expected int) {
+
+	if expected <= 0 {
+		return
+	}
+
+	for _, quote := range quotes {
+		ratios := append(h[quote.Code], float64(quote.Regular.Count)/float64(expected))
+		if len(ratios) > verifyHistoryDays {
+			ratios = ratios[len(ratios)-verifyHistoryDays:]
+		}
+		h[quote.Code] = ratios
+	}
+}
+
+// truncated 盘中报价数是否比该公司近期水平(按盘中分钟数折算，半日市也可以比较)少得多，没有近期数据或ratio不为正数时不检查
+func (h barHistory) truncated(quote market.CompanyDailyQuote, expected int, ratio float64) bool {
+
+	if ratio <= 0 || expected <= 0 {
+		return false
+	}
+
+	typical, found := h.typical(quote.Code)
+
+	return found && float64(quote.Regular.Count) < typical*ratio*float64(expected)
+}
+
+// typical 一家公司近期的盘中报价水平(中位数)，没有近期数据时found为false
+func (h barHistory) typical(code string) (float64, bool) {
+
+	ratios := append([]float64(nil), h[code]...)
+	if len(ratios) == 0 {
+		return 0, false
+	}
+
+	sort.Float64s(ratios)
+
+	return ratios[len(ratios)/2], true
+}
+
+// barHistory 读取某天之前最近verifyHistoryDays个有数据的交易日，作为各公司的近期盘中报价水平
+func (mr marketRecorder) barHistory(ctx context.Context, date time.Time) (barHistory, error) {
+
+	c := mr.Market.Calendar()
+
+	// 最多往前找两倍的交易日，跳过没有数据的日期
+	var days []time.Time
+	day := date
+	for tries := 0; tries < verifyHistoryDays*2 && len(days) < verifyHistoryDays; tries++ {
+
+		day = c.PrevTradingDay(day)
+
+		exists, err := mr.store.Exists(ctx, mr.Market, day)
+		if err != nil {
+			return nil, err
+		}
+
+		if exists {
+			days = append(days, day)
+		}
+	}
+
+	history := make(barHistory)
+	for index := len(days) - 1; index >= 0; index-- {
+
+		dailyQuote, err := mr.store.Load(ctx, mr.Market, days[index])
+		if err != nil {
+			return nil, fmt.Errorf("[%s] 读取%s的数据时发生错误: %v", mr.Name(), days[index].Format(datePattern), err)
+		}
+
+		history.add(dailyQuote.Quotes, mr.regularMinutes(days[index]))
+	}
+
+	return history, nil
+}
+
+// regularMinutes 当天盘中时段的分钟数(不含午休)，交易日历没有时段时为开盘至收盘
+func (mr marketRecorder) regularMinutes(date time.Time) int {
+
+	var minutes time.Duration
+	for _, session := range mr.Market.Sessions(date) {
+		if session.Type == calendar.SessionRegular {
+			minutes += session.End.Sub(session.Start)
+		}
+	}
+
+	if minutes == 0 {
+		c := mr.Market.Calendar()
+		minutes = c.Close(date).Sub(c.Open(date))
+	}
+
+	return int(minutes / time.Minute)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/nzai/stockrecorder/metrics"
+	"github.com/nzai/stockrecorder/recorder"
+)
+
+const (
+	// serverShutdownTimeout 关闭HTTP服务时等待进行中的请求的时长
+	serverShutdownTimeout = time.Second * 5
+)
+
+// HTTPConfig HTTP服务配置
+type HTTPConfig struct {
+	Address string `yaml:"address"` // 监听地址，如 :9090，为空时不启动
+}
+
+// serveHTTP 启动HTTP服务，ctx取消时关闭
+func serveHTTP(ctx context.Context, config HTTPConfig, r *recorder.Recorder) {
+
+	if config.Address == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/healthz", healthz)
+	mux.Handle("/status", statusHandler(r))
+
+	server := &http.Server{Addr: config.Address, Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+		defer cancel()
+
+		server.Shutdown(shutdownCtx)
+	}()
+
+	go func() {
+		log.Printf("HTTP服务已启动，监听地址: %s", config.Address)
+		err := server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("HTTP服务发生错误: %v", err)
+		}
+	}()
+}
+
+// healthz 存活检查，进程能响应即为健康
+func healthz(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// statusHandler 以JSON输出各市场记录器的当前状态
+func statusHandler(r *recorder.Recorder) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		buffer, err := json.MarshalIndent(r.Status(), "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write(append(buffer, '\n'))
+	})
+}
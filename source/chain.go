@@ -0,0 +1,190 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nzai/stockrecorder/market"
+)
+
+// ChainConfig 组合数据源配置
+type ChainConfig struct {
+	Default []string            `yaml:"default"` // 默认的数据源优先顺序，为空时按创建时的顺序
+	Markets map[string][]string `yaml:"markets"` // 各市场的数据源优先顺序，没有配置的市场使用默认顺序
+}
+
+// Chain 组合数据源，每家公司按优先顺序依次尝试各数据源，出错或报价为空时改用下一个
+type Chain struct {
+	sources []Source            // 默认优先顺序
+	markets map[string][]Source // 各市场的优先顺序
+}
+
+// NewChain 新建组合数据源
+func NewChain(config ChainConfig, sources ...Source) (*Chain, error) {
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("组合数据源至少需要一个数据源")
+	}
+
+	dict := make(map[string]Source, len(sources))
+	for _, source := range sources {
+		dict[strings.ToLower(source.Name())] = source
+	}
+
+	// 按名称排列数据源
+	order := func(names []string) ([]Source, error) {
+
+		var ordered []Source
+		for _, name := range names {
+
+			source, found := dict[strings.ToLower(name)]
+			if !found {
+				return nil, fmt.Errorf("未知的数据源:%s", name)
+			}
+
+			ordered = append(ordered, source)
+		}
+
+		return ordered, nil
+	}
+
+	chain := &Chain{sources: sources, markets: make(map[string][]Source)}
+	if len(config.Default) > 0 {
+		var err error
+		chain.sources, err = order(config.Default)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for name, names := range config.Markets {
+
+		ordered, err := order(names)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] %v", name, err)
+		}
+
+		if len(ordered) > 0 {
+			chain.markets[strings.ToLower(name)] = ordered
+		}
+	}
+
+	return chain, nil
+}
+
+// sourcesOf 某市场的数据源优先顺序
+func (c Chain) sourcesOf(_market market.Market) []Source {
+
+	if sources, found := c.markets[strings.ToLower(_market.Name())]; found {
+		return sources
+	}
+
+	return c.sources
+}
+
+// Name 名称
+func (c Chain) Name() string {
+
+	names := make([]string, 0, len(c.sources))
+	for _, source := range c.sources {
+		names = append(names, source.Name())
+	}
+
+	return "chain(" + strings.Join(names, ",") + ")"
+}
+
+// Expiration 各数据源中最长的有效期
+func (c Chain) Expiration() time.Duration {
+
+	var expiration time.Duration
+	for _, source := range c.allSources() {
+		if source.Expiration() > expiration {
+			expiration = source.Expiration()
+		}
+	}
+
+	return expiration
+}
+
+// Symbol 首选数据源的查询代码
+func (c Chain) Symbol(_market market.Market, company market.Company) string {
+	return c.sourcesOf(_market)[0].Symbol(_market, company)
+}
+
+// Crawl 按优先顺序依次尝试各数据源获取公司每天的报价
+func (c Chain) Crawl(ctx context.Context, _market market.Market, company market.Company, date time.Time) (*market.CompanyDailyQuote, error) {
+
+	var empty *market.CompanyDailyQuote
+	var errs []string
+	sources := c.sourcesOf(_market)
+	for index, source := range sources {
+
+		quote, err := source.Crawl(ctx, _market, company, date)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Sprintf("%s: %v", source.Name(), err))
+		case quote == nil:
+			errs = append(errs, fmt.Sprintf("%s: 没有返回报价", source.Name()))
+		case quote.Pre.Count+quote.Regular.Count+quote.Post.Count == 0:
+			// 报价为空可能是当天停牌，也可能是数据源缺失，先保留，其他数据源都没有时返回
+			if empty == nil {
+				empty = quote
+			}
+		default:
+			quote.Source = source.Name()
+			return quote, nil
+		}
+
+		if index+1 < len(sources) {
+			failovers.Inc(_market.Name(), source.Name())
+			log.Printf("[%s] %s在%s的报价从%s获取失败，改用%s", _market.Name(), company.Code, date.Format("20060102"), source.Name(), sources[index+1].Name())
+		}
+	}
+
+	if empty != nil {
+		return empty, nil
+	}
+
+	return nil, fmt.Errorf("所有数据源都获取失败: %s", strings.Join(errs, "; "))
+}
+
+// ParallelMax 各数据源中最小的并发数
+func (c Chain) ParallelMax() int {
+
+	parallel := 0
+	for _, source := range c.allSources() {
+		if parallel == 0 || source.ParallelMax() < parallel {
+			parallel = source.ParallelMax()
+		}
+	}
+
+	return parallel
+}
+
+// RetryCount 首选数据源的失败重试次数
+func (c Chain) RetryCount() int {
+	return c.sources[0].RetryCount()
+}
+
+// RetryInterval 首选数据源的失败重试时间间隔
+func (c Chain) RetryInterval() time.Duration {
+	return c.sources[0].RetryInterval()
+}
+
+// allSources 所有用到的数据源
+func (c Chain) allSources() []Source {
+
+	sources := append([]Source(nil), c.sources...)
+	for _, ordered := range c.markets {
+		sources = append(sources, ordered...)
+	}
+
+	return sources
+}
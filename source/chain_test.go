@@ -0,0 +1,188 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nzai/stockrecorder/market"
+)
+
+// fakeSource 按公司代码返回固定结果的数据源
+type fakeSource struct {
+	name       string
+	expiration time.Duration
+	parallel   int
+	quotes     map[string]*market.CompanyDailyQuote
+	calls      *int
+}
+
+// Name 名称
+func (s fakeSource) Name() string {
+	return s.name
+}
+
+// Expiration 有效期
+func (s fakeSource) Expiration() time.Duration {
+	return s.expiration
+}
+
+// Symbol 查询代码
+func (s fakeSource) Symbol(_market market.Market, company market.Company) string {
+	return s.name + ":" + company.Code
+}
+
+// Crawl 没有该公司时返回错误
+func (s fakeSource) Crawl(ctx context.Context, _market market.Market, company market.Company, date time.Time) (*market.CompanyDailyQuote, error) {
+
+	if s.calls != nil {
+		*s.calls++
+	}
+
+	quote, found := s.quotes[company.Code]
+	if !found {
+		return nil, errors.New("没有该公司")
+	}
+
+	if quote == nil {
+		return nil, nil
+	}
+
+	copied := *quote
+	return &copied, nil
+}
+
+// ParallelMax 最大并发数
+func (s fakeSource) ParallelMax() int {
+	return s.parallel
+}
+
+// RetryCount 失败重试次数
+func (s fakeSource) RetryCount() int {
+	return 1
+}
+
+// RetryInterval 失败重试时间间隔
+func (s fakeSource) RetryInterval() time.Duration {
+	return time.Second
+}
+
+// fakeQuote 有count笔盘中报价的公司
+func fakeQuote(code string, count uint32) *market.CompanyDailyQuote {
+	return &market.CompanyDailyQuote{Company: market.Company{Code: code}, Regular: market.QuoteSeries{Count: count}}
+}
+
+func TestChainFailover(t *testing.T) {
+
+	var secondCalls int
+	first := fakeSource{name: "first", expiration: time.Hour * 24 * 30, parallel: 8, quotes: map[string]*market.CompanyDailyQuote{
+		"AAPL": fakeQuote("AAPL", 390),
+		"MSFT": fakeQuote("MSFT", 0),
+		"IBM":  nil,
+	}}
+	second := fakeSource{name: "second", expiration: time.Hour * 24 * 60, parallel: 4, calls: &secondCalls, quotes: map[string]*market.CompanyDailyQuote{
+		"MSFT": fakeQuote("MSFT", 380),
+		"IBM":  fakeQuote("IBM", 370),
+		"SPY":  fakeQuote("SPY", 0),
+	}}
+
+	chain, err := NewChain(ChainConfig{}, first, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	date := time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		code   string
+		source string
+		count  uint32
+		fail   bool
+	}{
+		// 首选数据源成功时不再尝试其他数据源
+		{"AAPL", "first", 390, false},
+		// 报价为空时改用下一个数据源
+		{"MSFT", "second", 380, false},
+		// 没有返回报价时改用下一个数据源
+		{"IBM", "second", 370, false},
+		// 其他数据源失败时返回保留的空报价
+		{"SPY", "", 0, false},
+		{"QQQ", "", 0, true},
+	}
+
+	for _, test := range tests {
+
+		quote, err := chain.Crawl(ctx, market.America{}, market.Company{Code: test.code}, date)
+		if test.fail {
+			if err == nil {
+				t.Errorf("%s所有数据源都没有报价，应返回错误", test.code)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %v", test.code, err)
+			continue
+		}
+
+		if quote.Source != test.source || quote.Regular.Count != test.count {
+			t.Errorf("%s从%s获取了%d笔报价，应从%s获取%d笔", test.code, quote.Source, quote.Regular.Count, test.source, test.count)
+		}
+	}
+
+	if secondCalls != 4 {
+		t.Errorf("第二个数据源调用了%d次，应为4次", secondCalls)
+	}
+
+	if chain.Expiration() != second.expiration || chain.ParallelMax() != second.parallel {
+		t.Errorf("有效期%s、并发数%d应取各数据源中最长的有效期及最小的并发数", chain.Expiration(), chain.ParallelMax())
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+
+	first := fakeSource{name: "First", quotes: map[string]*market.CompanyDailyQuote{"0700": fakeQuote("0700", 330)}}
+	second := fakeSource{name: "Second", quotes: map[string]*market.CompanyDailyQuote{"0700": fakeQuote("0700", 320)}}
+
+	// 名称不区分大小写，没有配置的市场使用默认顺序
+	chain, err := NewChain(ChainConfig{Default: []string{"first", "second"}, Markets: map[string][]string{"HongKong": {"SECOND"}}}, first, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	date := time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	for _, test := range []struct {
+		_market market.Market
+		source  string
+	}{
+		{market.HongKong{}, "Second"},
+		{market.China{}, "First"},
+	} {
+		quote, err := chain.Crawl(ctx, test._market, market.Company{Code: "0700"}, date)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if quote.Source != test.source {
+			t.Errorf("[%s] 从%s获取了报价，应为%s", test._market.Name(), quote.Source, test.source)
+		}
+
+		if symbol := chain.Symbol(test._market, market.Company{Code: "0700"}); symbol != test.source+":0700" {
+			t.Errorf("[%s] 查询代码为%s，应使用%s的查询代码", test._market.Name(), symbol, test.source)
+		}
+	}
+
+	_, err = NewChain(ChainConfig{Default: []string{"third"}}, first, second)
+	if err == nil {
+		t.Error("未知的数据源应返回错误")
+	}
+
+	_, err = NewChain(ChainConfig{})
+	if err == nil {
+		t.Error("没有数据源时应返回错误")
+	}
+}
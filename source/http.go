@@ -0,0 +1,119 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	_url "net/url"
+	"time"
+)
+
+var (
+	// ErrTooManyRequests 请求过于频繁(HTTP 429)
+	ErrTooManyRequests = errors.New("请求过于频繁(HTTP 429)")
+)
+
+// StatusError 服务器返回了非2xx的状态码(HTTP 429除外，见ErrTooManyRequests)
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+// Error 错误信息
+func (e StatusError) Error() string {
+	return fmt.Sprintf("服务器返回了错误的状态码: %s", e.Status)
+}
+
+// Temporary 是否为服务器临时错误(5xx)，可以重试
+func (e StatusError) Temporary() bool {
+	return e.StatusCode >= http.StatusInternalServerError
+}
+
+// downloadStringRetry 访问网址并返回字符串，失败时(429、5xx及网络错误)按间隔重试，ctx取消时立即返回，name为记录指标用的数据源名称，每次请求前经过limiter限流
+func downloadStringRetry(ctx context.Context, limiter *Limiter, name, url string, retryTimes int, interval time.Duration) (string, error) {
+
+	var host string
+	if u, err := _url.Parse(url); err == nil {
+		host = u.Host
+	}
+
+	var err error
+	for times := retryTimes - 1; times >= 0; times-- {
+
+		var release func()
+		release, err = limiter.Wait(ctx, host)
+		if err != nil {
+			return "", err
+		}
+
+		var buffer []byte
+		buffer, err = downloadOnce(ctx, url)
+		release()
+
+		if err == ErrTooManyRequests {
+			throttled.Inc(name)
+		}
+		limiter.Report(err == ErrTooManyRequests, err)
+
+		if err == nil {
+			return string(buffer), nil
+		}
+
+		// 已取消的不再重试
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+
+		// 4xx(429除外)重试也不会成功
+		if status, ok := err.(StatusError); ok && !status.Temporary() {
+			return "", fmt.Errorf("访问%s出错，不再重试:%s", url, err.Error())
+		}
+
+		if times > 0 {
+			crawlRetries.Inc(name)
+			log.Printf("访问%s出错，还有%d次重试机会，%d秒后重试:%s", url, times, int64(interval.Seconds()), err.Error())
+
+			//	延时
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(interval):
+			}
+		}
+	}
+
+	return "", fmt.Errorf("访问%s出错，已重试%d次，不再重试:%s", url, retryTimes, err.Error())
+}
+
+// downloadOnce 访问网址并返回缓冲区
+func downloadOnce(ctx context.Context, url string) ([]byte, error) {
+
+	//	构造请求
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	//	发送请求
+	response, err := http.DefaultClient.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	//	被限流
+	if response.StatusCode == http.StatusTooManyRequests {
+		return nil, ErrTooManyRequests
+	}
+
+	//	其他非2xx都视为失败，不能把错误页面当作结果
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return nil, StatusError{StatusCode: response.StatusCode, Status: response.Status}
+	}
+
+	//	读取结果
+	return ioutil.ReadAll(response.Body)
+}
@@ -0,0 +1,187 @@
+package source
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// limiterMinRateRatio 自动降速后的最低速率与配置速率之比
+	limiterMinRateRatio = 1.0 / 16
+	// limiterRecoverRatio 每次成功请求后恢复的速率与配置速率之比
+	limiterRecoverRatio = 1.0 / 100
+	// limiterErrorBurst 连续出错多少次后降速
+	limiterErrorBurst = 5
+)
+
+// LimiterConfig 限流配置
+type LimiterConfig struct {
+	Rate        float64 `yaml:"rate"`        // 每秒请求数，为0时不限速
+	Burst       int     `yaml:"burst"`       // 令牌桶容量，即允许的突发请求数，默认为1
+	Concurrency int     `yaml:"concurrency"` // 每个域名的最大并发请求数，为0时不限
+}
+
+// Limiter 令牌桶限流器，同一个数据源的所有请求(不论哪个市场)共用，收到HTTP 429或连续出错时自动降速，之后逐步恢复
+type Limiter struct {
+	name   string
+	config LimiterConfig
+
+	mutex  sync.Mutex
+	rate   float64   // 当前速率
+	tokens float64   // 当前令牌数
+	last   time.Time // 上次补充令牌的时间
+	errors int       // 连续出错次数
+
+	hostsMutex sync.Mutex
+	hosts      map[string]chan bool // 各域名的并发槽位
+}
+
+// NewLimiter 新建限流器，name为记录指标用的数据源名称
+func NewLimiter(name string, config LimiterConfig) *Limiter {
+
+	if config.Burst <= 0 {
+		config.Burst = 1
+	}
+
+	l := &Limiter{
+		name:   name,
+		config: config,
+		rate:   config.Rate,
+		tokens: float64(config.Burst),
+		last:   time.Now(),
+		hosts:  make(map[string]chan bool),
+	}
+	limiterRate.Set(l.rate, name)
+
+	return l
+}
+
+// Wait 等待host的并发槽位和令牌，成功后需要调用返回的release释放槽位
+func (l *Limiter) Wait(ctx context.Context, host string) (release func(), err error) {
+
+	if l == nil {
+		return func() {}, nil
+	}
+
+	release = func() {}
+	if l.config.Concurrency > 0 {
+		slots := l.slots(host)
+		select {
+		case slots <- false:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		release = func() { <-slots }
+	}
+
+	err = l.take(ctx)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	return release, nil
+}
+
+// slots 某域名的并发槽位
+func (l *Limiter) slots(host string) chan bool {
+
+	l.hostsMutex.Lock()
+	defer l.hostsMutex.Unlock()
+
+	slots, found := l.hosts[host]
+	if !found {
+		slots = make(chan bool, l.config.Concurrency)
+		l.hosts[host] = slots
+	}
+
+	return slots
+}
+
+// take 取一个令牌，没有令牌时等待
+func (l *Limiter) take(ctx context.Context) error {
+
+	if l.config.Rate <= 0 {
+		return nil
+	}
+
+	for {
+		l.mutex.Lock()
+		l.refill(time.Now())
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mutex.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// refill 按当前速率补充令牌
+func (l *Limiter) refill(now time.Time) {
+
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > float64(l.config.Burst) {
+		l.tokens = float64(l.config.Burst)
+	}
+	l.last = now
+}
+
+// Report 报告一次请求的结果，throttled表示收到了HTTP 429
+func (l *Limiter) Report(throttled bool, err error) {
+
+	if l == nil || l.config.Rate <= 0 {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.refill(time.Now())
+
+	switch {
+	case throttled:
+		l.errors = 0
+		l.tokens = 0
+		l.slowDown("收到HTTP 429")
+	case err != nil:
+		l.errors++
+		if l.errors >= limiterErrorBurst {
+			l.errors = 0
+			l.slowDown("连续出错")
+		}
+	default:
+		l.errors = 0
+		if l.rate < l.config.Rate {
+			l.rate += l.config.Rate * limiterRecoverRatio
+			if l.rate > l.config.Rate {
+				l.rate = l.config.Rate
+			}
+			limiterRate.Set(l.rate, l.name)
+		}
+	}
+}
+
+// slowDown 速率减半，不低于最低速率
+func (l *Limiter) slowDown(reason string) {
+
+	rate := l.rate / 2
+	if min := l.config.Rate * limiterMinRateRatio; rate < min {
+		rate = min
+	}
+
+	if rate < l.rate {
+		log.Printf("数据源%s%s，请求速率由每秒%.2f次降至%.2f次", l.name, reason, l.rate, rate)
+		l.rate = rate
+		limiterRate.Set(l.rate, l.name)
+	}
+}
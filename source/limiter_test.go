@@ -0,0 +1,121 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// rateOf 当前速率
+func rateOf(l *Limiter) float64 {
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.rate
+}
+
+func TestLimiterSlowDown(t *testing.T) {
+
+	l := NewLimiter("test", LimiterConfig{Rate: 100})
+
+	// 收到HTTP 429时减半，不低于配置速率的1/16
+	for _, expected := range []float64{50, 25, 12.5, 6.25, 6.25} {
+		l.Report(true, nil)
+		if rate := rateOf(l); rate != expected {
+			t.Fatalf("收到HTTP 429后速率为%.2f，应为%.2f", rate, expected)
+		}
+	}
+
+	l = NewLimiter("test", LimiterConfig{Rate: 100})
+
+	// 连续出错5次才减半
+	err := errors.New("请求失败")
+	for index := 1; index < limiterErrorBurst; index++ {
+		l.Report(false, err)
+	}
+	if rate := rateOf(l); rate != 100 {
+		t.Fatalf("连续出错%d次后速率为%.2f，不应降速", limiterErrorBurst-1, rate)
+	}
+
+	l.Report(false, err)
+	if rate := rateOf(l); rate != 50 {
+		t.Fatalf("连续出错%d次后速率为%.2f，应为50", limiterErrorBurst, rate)
+	}
+
+	// 中间有成功的请求时重新计数
+	for index := 1; index < limiterErrorBurst; index++ {
+		l.Report(false, err)
+	}
+	l.Report(false, nil)
+	l.Report(false, err)
+	if rate := rateOf(l); rate != 51 {
+		t.Fatalf("出错次数没有连续时速率为%.2f，应为51", rate)
+	}
+}
+
+func TestLimiterRecover(t *testing.T) {
+
+	l := NewLimiter("test", LimiterConfig{Rate: 100})
+	l.Report(true, nil)
+
+	// 每次成功恢复配置速率的1/100
+	for index := 0; index < 10; index++ {
+		l.Report(false, nil)
+	}
+	if rate := rateOf(l); math.Abs(rate-60) > 1e-9 {
+		t.Fatalf("成功10次后速率为%.2f，应为60", rate)
+	}
+
+	// 不超过配置速率
+	for index := 0; index < 100; index++ {
+		l.Report(false, nil)
+	}
+	if rate := rateOf(l); rate != 100 {
+		t.Fatalf("恢复后速率为%.2f，应为100", rate)
+	}
+}
+
+func TestLimiterWait(t *testing.T) {
+
+	l := NewLimiter("test", LimiterConfig{Rate: 1000, Burst: 2, Concurrency: 1})
+	ctx := context.Background()
+
+	release, err := l.Wait(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 同一域名的并发槽位已满时等待，其他域名不受影响
+	timeout, cancel := context.WithTimeout(ctx, time.Millisecond*50)
+	defer cancel()
+
+	_, err = l.Wait(timeout, "example.com")
+	if err != context.DeadlineExceeded {
+		t.Fatalf("并发槽位已满时应等待至超时: %v", err)
+	}
+
+	other, err := l.Wait(ctx, "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other()
+
+	release()
+	release, err = l.Wait(ctx, "example.com")
+	if err != nil {
+		t.Fatalf("释放槽位后应能继续请求: %v", err)
+	}
+	release()
+
+	// nil表示不限流
+	var unlimited *Limiter
+	release, err = unlimited.Wait(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	unlimited.Report(true, nil)
+}
@@ -0,0 +1,18 @@
+package source
+
+import (
+	"github.com/nzai/stockrecorder/metrics"
+)
+
+var (
+	// crawlDuration 抓取一家公司一天的报价的耗时(含重试)
+	crawlDuration = metrics.NewHistogram("stockrecorder_source_crawl_duration_seconds", "抓取一家公司一天的报价的耗时(含重试)", metrics.DefaultBuckets, "source", "result")
+	// crawlRetries 请求重试次数
+	crawlRetries = metrics.NewCounter("stockrecorder_source_retries_total", "数据源请求的重试次数", "source")
+	// throttled 收到HTTP 429的次数
+	throttled = metrics.NewCounter("stockrecorder_source_throttled_total", "数据源请求收到HTTP 429的次数", "source")
+	// limiterRate 限流器当前的请求速率
+	limiterRate = metrics.NewGauge("stockrecorder_source_rate_limit", "限流器当前每秒允许的请求数，0为不限速", "source")
+	// failovers 数据源失败后改用下一个数据源的次数
+	failovers = metrics.NewCounter("stockrecorder_source_failovers_total", "数据源失败后改用下一个数据源的次数", "market", "source")
+)
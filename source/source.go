@@ -1,6 +1,7 @@
 package source
 
 import (
+	"context"
 	"time"
 
 	"github.com/nzai/stockrecorder/market"
@@ -8,10 +9,14 @@ import (
 
 // Source 数据源
 type Source interface {
+	// 名称
+	Name() string
 	// 数据能报保存多长时间(能查到的最早数据距今多长时间)
 	Expiration() time.Duration
+	// 上市公司在数据源的查询代码
+	Symbol(_market market.Market, company market.Company) string
 	// 获取公司每日报价
-	Crawl(_market market.Market, company market.Company, date time.Time) (*market.CompanyDailyQuote, error)
+	Crawl(ctx context.Context, _market market.Market, company market.Company, date time.Time) (*market.CompanyDailyQuote, error)
 	// 最大并发数
 	ParallelMax() int
 	// 失败重试次数
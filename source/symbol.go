@@ -0,0 +1,94 @@
+package source
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nzai/stockrecorder/market"
+)
+
+// SymbolConfig 数据源查询代码配置，市场名称不区分大小写
+type SymbolConfig struct {
+	Suffixes  map[string]string            `yaml:"suffixes"`  // 各市场查询代码的后缀，覆盖内置规则，如 japan: .T
+	Overrides map[string]map[string]string `yaml:"overrides"` // 各市场指定代码的查询代码，优先于其他规则，如 america: {BRK.B: BRK-B}
+}
+
+// Validate 校验配置
+func (c SymbolConfig) Validate() error {
+
+	for name, overrides := range c.Overrides {
+		for code, symbol := range overrides {
+			if strings.TrimSpace(code) == "" || strings.TrimSpace(symbol) == "" {
+				return fmt.Errorf("[%s] 查询代码不能为空:%s -> %s", name, code, symbol)
+			}
+		}
+	}
+
+	return nil
+}
+
+// SymbolRule 内置的查询代码规则
+type SymbolRule func(company market.Company) string
+
+// SuffixRule 在代码后加上后缀的规则
+func SuffixRule(suffix string) SymbolRule {
+	return func(company market.Company) string {
+		return company.Code + suffix
+	}
+}
+
+// Symbols 上市公司到数据源查询代码的转换，依次按指定代码、市场后缀、内置规则，都没有时使用公司代码
+type Symbols struct {
+	rules     map[string]SymbolRule
+	suffixes  map[string]string
+	overrides map[string]map[string]string
+}
+
+// NewSymbols 新建查询代码转换，rules为数据源按市场名称的内置规则
+func NewSymbols(rules map[string]SymbolRule, config SymbolConfig) Symbols {
+
+	s := Symbols{
+		rules:     make(map[string]SymbolRule, len(rules)),
+		suffixes:  make(map[string]string, len(config.Suffixes)),
+		overrides: make(map[string]map[string]string, len(config.Overrides)),
+	}
+
+	for name, rule := range rules {
+		s.rules[strings.ToLower(name)] = rule
+	}
+
+	for name, suffix := range config.Suffixes {
+		s.suffixes[strings.ToLower(name)] = suffix
+	}
+
+	for name, overrides := range config.Overrides {
+
+		codes := make(map[string]string, len(overrides))
+		for code, symbol := range overrides {
+			codes[strings.ToUpper(strings.TrimSpace(code))] = strings.TrimSpace(symbol)
+		}
+
+		s.overrides[strings.ToLower(name)] = codes
+	}
+
+	return s
+}
+
+// Symbol 上市公司在数据源的查询代码
+func (s Symbols) Symbol(_market market.Market, company market.Company) string {
+
+	name := strings.ToLower(_market.Name())
+	if symbol, found := s.overrides[name][strings.ToUpper(company.Code)]; found {
+		return symbol
+	}
+
+	if suffix, found := s.suffixes[name]; found {
+		return company.Code + suffix
+	}
+
+	if rule, found := s.rules[name]; found {
+		return rule(company)
+	}
+
+	return company.Code
+}
@@ -1,21 +1,73 @@
 package source
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
 
-	"github.com/nzai/go-utility/net"
+	"github.com/nzai/stockrecorder/calendar"
 	"github.com/nzai/stockrecorder/market"
 )
 
+const (
+	// yahooDefaultHost 雅虎财经接口默认的域名
+	yahooDefaultHost = "query2.finance.yahoo.com"
+)
+
+var (
+	// yahooSymbolRules 雅虎财经内置的查询代码规则，美股直接使用代码
+	yahooSymbolRules = map[string]SymbolRule{
+		"china":    yahooChinaSymbol,
+		"hongkong": SuffixRule(".HK"),
+		"england":  SuffixRule(".L"),
+	}
+)
+
 // YahooFinance 雅虎财经数据源
-type YahooFinance struct{}
+type YahooFinance struct {
+	name    string   // 名称
+	host    string   // 接口域名
+	limiter *Limiter // 限流器
+	symbols Symbols  // 查询代码
+}
 
 // NewYahooFinance 新建雅虎财经数据源
-func NewYahooFinance() YahooFinance {
-	return YahooFinance{}
+func NewYahooFinance(limit LimiterConfig, symbols SymbolConfig) YahooFinance {
+	return NewYahooFinanceHost("yahoo", yahooDefaultHost, limit, symbols)
+}
+
+// NewYahooFinanceHost 新建使用指定接口域名(如query1.finance.yahoo.com)的雅虎财经数据源
+func NewYahooFinanceHost(name, host string, limit LimiterConfig, symbols SymbolConfig) YahooFinance {
+	return YahooFinance{name: name, host: host, limiter: NewLimiter(name, limit), symbols: NewSymbols(yahooSymbolRules, symbols)}
+}
+
+// yahooChinaSymbol A股的查询代码，深市加.SZ，沪市加.SS
+func yahooChinaSymbol(company market.Company) string {
+
+	var suffix string
+	switch company.Code[:1] {
+	case "0":
+		suffix = "SZ"
+	case "2":
+		suffix = "SZ"
+	case "3":
+		suffix = "SZ"
+	case "9":
+		suffix = "SS"
+	case "6":
+		suffix = "SS"
+	default:
+		suffix = "SS"
+	}
+
+	return company.Code + "." + suffix
+}
+
+// Name 名称
+func (yahoo YahooFinance) Name() string {
+	return yahoo.name
 }
 
 // Expiration 最早能查到60天前的数据
@@ -23,18 +75,42 @@ func (yahoo YahooFinance) Expiration() time.Duration {
 	return time.Hour * 24 * 30
 }
 
+// Symbol 雅虎财经查询代码
+func (yahoo YahooFinance) Symbol(_market market.Market, company market.Company) string {
+	return yahoo.symbols.Symbol(_market, company)
+}
+
 // Crawl 获取公司每天的报价
-func (yahoo YahooFinance) Crawl(_market market.Market, company market.Company, date time.Time) (*market.CompanyDailyQuote, error) {
+func (yahoo YahooFinance) Crawl(ctx context.Context, _market market.Market, company market.Company, date time.Time) (*market.CompanyDailyQuote, error) {
+
+	start := time.Now()
+	quote, err := yahoo.crawl(ctx, _market, company, date)
+
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	crawlDuration.Observe(time.Since(start).Seconds(), yahoo.name, result)
+
+	if quote != nil {
+		quote.Source = yahoo.name
+	}
+
+	return quote, err
+}
+
+// crawl 获取公司每天的报价
+func (yahoo YahooFinance) crawl(ctx context.Context, _market market.Market, company market.Company, date time.Time) (*market.CompanyDailyQuote, error) {
 
 	// 起止时间
 	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
 	end := start.AddDate(0, 0, 1)
 
-	pattern := "https://query2.finance.yahoo.com/v8/finance/chart/%s?period2=%d&period1=%d&interval=1m&indicators=quote&includeTimestamps=true&includePrePost=true&events=div%%7Csplit%%7Cearn&corsDomain=finance.yahoo.com"
-	url := fmt.Sprintf(pattern, _market.YahooQueryCode(company), end.Unix(), start.Unix())
+	pattern := "https://%s/v8/finance/chart/%s?period2=%d&period1=%d&interval=1m&indicators=quote&includeTimestamps=true&includePrePost=true&events=div%%7Csplit%%7Cearn&corsDomain=finance.yahoo.com"
+	url := fmt.Sprintf(pattern, yahoo.host, yahoo.Symbol(_market, company), end.Unix(), start.Unix())
 
 	// 查询Yahoo财经接口,返回股票分时数据
-	str, err := net.DownloadStringRetry(url, yahoo.RetryCount(), yahoo.RetryInterval())
+	str, err := downloadStringRetry(ctx, yahoo.limiter, yahoo.name, url, yahoo.RetryCount(), yahoo.RetryInterval())
 	if err != nil {
 		return nil, err
 	}
@@ -85,12 +161,8 @@ func (yahoo YahooFinance) valid(quote *YahooQuote) error {
 		return errors.New("Quotes数量不正确")
 	}
 
-	// TradingPeriods数量不正确
-	if len(result.Meta.TradingPeriods.Pres) == 0 ||
-		len(result.Meta.TradingPeriods.Pres[0]) == 0 ||
-		len(result.Meta.TradingPeriods.Posts) == 0 ||
-		len(result.Meta.TradingPeriods.Posts[0]) == 0 ||
-		len(result.Meta.TradingPeriods.Regulars) == 0 ||
+	// 没有盘中时段(没有盘前盘后的市场可以为空)
+	if len(result.Meta.TradingPeriods.Regulars) == 0 ||
 		len(result.Meta.TradingPeriods.Regulars[0]) == 0 {
 		return errors.New("TradingPeriods数量不正确")
 	}
@@ -103,7 +175,14 @@ func (yahoo YahooFinance) parse(_market market.Market, company market.Company, d
 
 	companyDailyQuote := market.CompanyDailyQuote{Company: company}
 
-	periods, _quote := quote.Chart.Result[0].Meta.TradingPeriods, quote.Chart.Result[0].Indicators.Quotes[0]
+	// 上市公司列表中没有币种时使用雅虎财经返回的币种
+	if companyDailyQuote.Currency == "" {
+		companyDailyQuote.Currency = quote.Chart.Result[0].Meta.Currency
+	}
+
+	// 按雅虎财经返回的全部时段(可能有多个盘中时段)归类报价
+	segments := yahoo.segments(quote)
+	_quote := quote.Chart.Result[0].Indicators.Quotes[0]
 	for index, ts := range quote.Chart.Result[0].Timestamp {
 
 		//	如果全为0就忽略
@@ -111,17 +190,20 @@ func (yahoo YahooFinance) parse(_market market.Market, company market.Company, d
 			continue
 		}
 
-		var series *market.QuoteSeries
+		segment, found := segments.Find(uint32(ts))
+		if !found {
+			continue
+		}
 
 		//	Pre, Regular, Post
-		if ts >= periods.Pres[0][0].Start && ts < periods.Pres[0][0].End {
+		var series *market.QuoteSeries
+		switch segment.Type {
+		case calendar.SessionPre:
 			series = &companyDailyQuote.Pre
-		} else if ts >= periods.Regulars[0][0].Start && ts < periods.Regulars[0][0].End {
-			series = &companyDailyQuote.Regular
-		} else if ts >= periods.Posts[0][0].Start && ts < periods.Posts[0][0].End {
+		case calendar.SessionPost:
 			series = &companyDailyQuote.Post
-		} else {
-			continue
+		default:
+			series = &companyDailyQuote.Regular
 		}
 
 		series.Count++
@@ -133,9 +215,88 @@ func (yahoo YahooFinance) parse(_market market.Market, company market.Company, d
 		series.Volume = append(series.Volume, uint32(_quote.Volume[index]))
 	}
 
+	// 记录市场的交易时段(如午休前后的两个盘中时段)，交易日历没有时使用雅虎财经返回的时段
+	companyDailyQuote.Segments = market.NewSegments(_market.Sessions(date))
+	if len(companyDailyQuote.Segments) == 0 {
+		companyDailyQuote.Segments = segments
+	}
+
+	companyDailyQuote.Events = yahoo.events(company, date, quote)
+
 	return &companyDailyQuote, nil
 }
 
+// events 解析当天的分红、拆股及财报事件
+func (yahoo YahooFinance) events(company market.Company, date time.Time, quote *YahooQuote) market.EventList {
+
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location()).Unix()
+	end := start + 24*60*60
+
+	var events market.EventList
+	add := func(event market.Event) {
+		// 只保留当天的事件
+		if event.Timestamp >= start && event.Timestamp < end {
+			event.Code = company.Code
+			events = append(events, event)
+		}
+	}
+
+	_events := quote.Chart.Result[0].Events
+	for _, dividend := range _events.Dividends {
+		add(market.Event{Type: market.EventDividend, Timestamp: dividend.Date, Amount: dividend.Amount})
+	}
+
+	for _, split := range _events.Splits {
+		add(market.Event{Type: market.EventSplit, Timestamp: split.Date, Numerator: split.Numerator, Denominator: split.Denominator})
+	}
+
+	for _, earnings := range _events.Earnings {
+		add(market.Event{Type: market.EventEarnings, Timestamp: earnings.Date})
+	}
+
+	events.Sort()
+
+	return events
+}
+
+// segments 雅虎财经返回的全部交易时段，按盘前、盘中、盘后排列，同类有多个时段时名称加上序号
+func (yahoo YahooFinance) segments(quote *YahooQuote) market.SegmentList {
+
+	periods := quote.Chart.Result[0].Meta.TradingPeriods
+
+	var segments market.SegmentList
+	for _, group := range []struct {
+		_type   string
+		periods [][]YahooPeriod
+	}{
+		{calendar.SessionPre, periods.Pres},
+		{calendar.SessionRegular, periods.Regulars},
+		{calendar.SessionPost, periods.Posts},
+	} {
+		var flat []YahooPeriod
+		for _, day := range group.periods {
+			flat = append(flat, day...)
+		}
+
+		for index, period := range flat {
+
+			name := group._type
+			if len(flat) > 1 {
+				name = fmt.Sprintf("%s%d", group._type, index+1)
+			}
+
+			segments = append(segments, market.Segment{
+				Name:  name,
+				Type:  group._type,
+				Start: uint32(period.Start),
+				End:   uint32(period.End),
+			})
+		}
+	}
+
+	return segments
+}
+
 // ParallelMax 最大并发数
 func (yahoo YahooFinance) ParallelMax() int {
 	return 32
@@ -186,24 +347,9 @@ type YahooQuote struct {
 					} `json:"post"`
 				} `json:"currentTradingPeriod"`
 				TradingPeriods struct {
-					Pres [][]struct {
-						Timezone  string `json:"timezone"`
-						Start     int64  `json:"start"`
-						End       int64  `json:"end"`
-						GMTOffset int64  `json:"gmtoffset"`
-					} `json:"pre"`
-					Regulars [][]struct {
-						Timezone  string `json:"timezone"`
-						Start     int64  `json:"start"`
-						End       int64  `json:"end"`
-						GMTOffset int64  `json:"gmtoffset"`
-					} `json:"regular"`
-					Posts [][]struct {
-						Timezone  string `json:"timezone"`
-						Start     int64  `json:"start"`
-						End       int64  `json:"end"`
-						GMTOffset int64  `json:"gmtoffset"`
-					} `json:"post"`
+					Pres     [][]YahooPeriod `json:"pre"`
+					Regulars [][]YahooPeriod `json:"regular"`
+					Posts    [][]YahooPeriod `json:"post"`
 				} `json:"tradingPeriods"`
 				DataGranularity string   `json:"dataGranularity"`
 				ValidRanges     []string `json:"validRanges"`
@@ -218,6 +364,21 @@ type YahooQuote struct {
 					Volume []int64   `json:"volume"`
 				} `json:"quote"`
 			} `json:"indicators"`
+			Events struct {
+				Dividends map[string]struct {
+					Amount float64 `json:"amount"`
+					Date   int64   `json:"date"`
+				} `json:"dividends"`
+				Splits map[string]struct {
+					Date        int64   `json:"date"`
+					Numerator   float64 `json:"numerator"`
+					Denominator float64 `json:"denominator"`
+					SplitRatio  string  `json:"splitRatio"`
+				} `json:"splits"`
+				Earnings map[string]struct {
+					Date int64 `json:"date"`
+				} `json:"earnings"`
+			} `json:"events"`
 		} `json:"result"`
 		Err *struct {
 			Code        string `json:"code"`
@@ -225,3 +386,11 @@ type YahooQuote struct {
 		} `json:"error"`
 	} `json:"chart"`
 }
+
+// YahooPeriod 雅虎财经返回的交易时段
+type YahooPeriod struct {
+	Timezone  string `json:"timezone"`
+	Start     int64  `json:"start"`
+	End       int64  `json:"end"`
+	GMTOffset int64  `json:"gmtoffset"`
+}
@@ -3,6 +3,7 @@ package store
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -50,40 +51,52 @@ func (s AliyunOSS) objectKey(_market market.Market, date time.Time) string {
 }
 
 // Exists 判断是否存在
-func (s AliyunOSS) Exists(_market market.Market, date time.Time) (bool, error) {
+func (s AliyunOSS) Exists(ctx context.Context, _market market.Market, date time.Time) (bool, error) {
+
+	// OSS SDK不支持context，只能在请求前检查
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
 	return s.bucket.IsObjectExist(s.objectKey(_market, date))
 }
 
-// Save 保存
-func (s AliyunOSS) Save(quote market.DailyQuote) error {
+// Save 保存，返回写入的字节数
+func (s AliyunOSS) Save(ctx context.Context, quote market.DailyQuote) (int, error) {
 
-	// gzip 最高压缩
-	buffer := new(bytes.Buffer)
-	w, err := gzip.NewWriterLevel(buffer, gzip.BestCompression)
-	if err != nil {
-		return err
-	}
-	_, err = w.Write(quote.Marshal())
+	// gzip 最高压缩后上传
+	size, err := saveDay(ctx, s, quote, func(zipped []byte) error {
+		return s.bucket.PutObject(s.objectKey(quote.Market, quote.Date), bytes.NewReader(zipped))
+	})
 	if err != nil {
-		return err
+		return 0, err
 	}
-	w.Flush()
-	w.Close()
 
-	zipped, err := ioutil.ReadAll(buffer)
+	saveBytes.Observe(float64(size), "aliyun")
+
+	return size, nil
+}
+
+// Load 读取，按公司保存的报价覆盖当天整体数据中的同一家公司
+func (s AliyunOSS) Load(ctx context.Context, _market market.Market, date time.Time) (market.DailyQuote, error) {
+
+	mdq, err := s.loadDay(ctx, _market, date)
 	if err != nil {
-		return err
+		return mdq, err
 	}
 
-	// 上传
-	return s.bucket.PutObject(s.objectKey(quote.Market, quote.Date), bytes.NewReader(zipped))
+	return mdq, overlayCompanies(ctx, s, &mdq)
 }
 
-// Load 读取
-func (s AliyunOSS) Load(_market market.Market, date time.Time) (market.DailyQuote, error) {
+// loadDay 读取当天整体数据
+func (s AliyunOSS) loadDay(ctx context.Context, _market market.Market, date time.Time) (market.DailyQuote, error) {
 
 	mdq := market.DailyQuote{Market: _market, Date: date}
 
+	if err := ctx.Err(); err != nil {
+		return mdq, err
+	}
+
 	readCloser, err := s.bucket.GetObject(s.objectKey(_market, date))
 	if err != nil {
 		return mdq, err
@@ -105,3 +118,207 @@ func (s AliyunOSS) Load(_market market.Market, date time.Time) (market.DailyQuot
 
 	return mdq, nil
 }
+
+// getRange 读取当天整体数据中的一段
+func (s AliyunOSS) getRange(ctx context.Context, _market market.Market, date time.Time, offset, length int64) ([]byte, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	readCloser, err := s.bucket.GetObject(s.objectKey(_market, date), oss.Range(offset, offset+length-1))
+	if err != nil {
+		return nil, err
+	}
+	defer readCloser.Close()
+
+	return ioutil.ReadAll(readCloser)
+}
+
+// companiesKey 上市公司列表的存储路径
+func (s AliyunOSS) companiesKey(_market market.Market, date time.Time) string {
+	return fmt.Sprintf("%s%s/%s.companies", s.config.KeyRoot, date.Format("2006/01/02"), strings.ToLower(_market.Name()))
+}
+
+// SaveCompanies 保存某天的上市公司列表
+func (s AliyunOSS) SaveCompanies(ctx context.Context, _market market.Market, date time.Time, companies []market.Company) error {
+
+	zipped, err := gzipBytes(market.CompanyList(companies).Marshal())
+	if err != nil {
+		return err
+	}
+
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.bucket.PutObject(s.companiesKey(_market, date), bytes.NewReader(zipped))
+}
+
+// LoadCompanies 读取某天的上市公司列表
+func (s AliyunOSS) LoadCompanies(ctx context.Context, _market market.Market, date time.Time) ([]market.Company, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	readCloser, err := s.bucket.GetObject(s.companiesKey(_market, date))
+	if err != nil {
+		return nil, err
+	}
+	defer readCloser.Close()
+
+	zipped, err := ioutil.ReadAll(readCloser)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer, err := gunzipBytes(zipped)
+	if err != nil {
+		return nil, err
+	}
+
+	var companies market.CompanyList
+	companies.Unmarshal(buffer)
+
+	return companies, nil
+}
+
+// attachmentKey 附件的存储路径
+func (s AliyunOSS) attachmentKey(_market market.Market, date time.Time, name string) string {
+	return fmt.Sprintf("%s%s/%s.%s", s.config.KeyRoot, date.Format("2006/01/02"), strings.ToLower(_market.Name()), name)
+}
+
+// SaveAttachment 保存某天的附件
+func (s AliyunOSS) SaveAttachment(ctx context.Context, _market market.Market, date time.Time, name string, data []byte) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.bucket.PutObject(s.attachmentKey(_market, date, name), bytes.NewReader(data))
+}
+
+// LoadAttachment 读取某天的附件
+func (s AliyunOSS) LoadAttachment(ctx context.Context, _market market.Market, date time.Time, name string) ([]byte, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	readCloser, err := s.bucket.GetObject(s.attachmentKey(_market, date, name))
+	if err != nil {
+		if se, ok := err.(oss.ServiceError); ok && se.StatusCode == 404 {
+			return nil, ErrAttachmentNotFound
+		}
+		return nil, err
+	}
+	defer readCloser.Close()
+
+	return ioutil.ReadAll(readCloser)
+}
+
+// companyPrefix 按公司保存的报价的路径前缀
+func (s AliyunOSS) companyPrefix(_market market.Market, date time.Time) string {
+	return fmt.Sprintf("%s%s/%s/", s.config.KeyRoot, date.Format("2006/01/02"), strings.ToLower(_market.Name()))
+}
+
+// putCompany 写入一家公司的报价
+func (s AliyunOSS) putCompany(ctx context.Context, _market market.Market, date time.Time, code string, zipped []byte) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.bucket.PutObject(s.companyPrefix(_market, date)+companyFileName(code), bytes.NewReader(zipped))
+}
+
+// getCompany 读取一家公司的报价
+func (s AliyunOSS) getCompany(ctx context.Context, _market market.Market, date time.Time, code string) ([]byte, bool, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	readCloser, err := s.bucket.GetObject(s.companyPrefix(_market, date) + companyFileName(code))
+	if err != nil {
+		if se, ok := err.(oss.ServiceError); ok && se.StatusCode == 404 {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	defer readCloser.Close()
+
+	zipped, err := ioutil.ReadAll(readCloser)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return zipped, true, nil
+}
+
+// deleteCompany 删除一家公司的报价
+func (s AliyunOSS) deleteCompany(ctx context.Context, _market market.Market, date time.Time, code string) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// OSS删除不存在的Object也返回成功
+	return s.bucket.DeleteObject(s.companyPrefix(_market, date) + companyFileName(code))
+}
+
+// companyCodes 当天按公司保存的公司代码
+func (s AliyunOSS) companyCodes(ctx context.Context, _market market.Market, date time.Time) ([]string, error) {
+
+	prefix := s.companyPrefix(_market, date)
+
+	var codes []string
+	marker := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		result, err := s.bucket.ListObjects(oss.Prefix(prefix), oss.Marker(marker))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range result.Objects {
+			if code, ok := companyCode(strings.TrimPrefix(object.Key, prefix)); ok {
+				codes = append(codes, code)
+			}
+		}
+
+		if !result.IsTruncated {
+			return codes, nil
+		}
+		marker = result.NextMarker
+	}
+}
+
+// SaveCompany 按公司保存某天的报价，返回写入的字节数
+func (s AliyunOSS) SaveCompany(ctx context.Context, _market market.Market, date time.Time, quote market.CompanyDailyQuote) (int, error) {
+	return saveCompany(ctx, s, _market, date, quote)
+}
+
+// LoadCompany 读取一家公司某天的报价
+func (s AliyunOSS) LoadCompany(ctx context.Context, _market market.Market, date time.Time, code string) (market.CompanyDailyQuote, error) {
+	return loadCompany(ctx, s, _market, date, code, func() (market.DailyQuote, error) {
+		return s.loadDay(ctx, _market, date)
+	})
+}
+
+// ListCompanies 某天有报价的公司代码
+func (s AliyunOSS) ListCompanies(ctx context.Context, _market market.Market, date time.Time) ([]string, error) {
+
+	exists, err := s.Exists(ctx, _market, date)
+	if err != nil {
+		return nil, err
+	}
+
+	return listCompanies(ctx, s, _market, date, exists, func() (market.DailyQuote, error) {
+		return s.loadDay(ctx, _market, date)
+	})
+}
@@ -3,6 +3,7 @@ package store
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"strings"
@@ -52,9 +53,9 @@ func NewAmazonS3(s3config AmazonS3Config) AmazonS3 {
 }
 
 // Exists 判断某天的数据是否存在
-func (s AmazonS3) Exists(_market market.Market, date time.Time) (bool, error) {
+func (s AmazonS3) Exists(ctx context.Context, _market market.Market, date time.Time) (bool, error) {
 
-	_, err := s.svc.HeadObject(&s3.HeadObjectInput{
+	_, err := s.svc.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(s.config.Bucket),
 		Key:    aws.String(s.savePath(_market, date)),
 	})
@@ -71,36 +72,26 @@ func (s AmazonS3) Exists(_market market.Market, date time.Time) (bool, error) {
 	return false, err
 }
 
-// Save 保存
-func (s AmazonS3) Save(quote market.DailyQuote) error {
+// Save 保存，返回写入的字节数
+func (s AmazonS3) Save(ctx context.Context, quote market.DailyQuote) (int, error) {
 
-	// gzip 最高压缩
-	buffer := new(bytes.Buffer)
-	w, err := gzip.NewWriterLevel(buffer, gzip.BestCompression)
-	if err != nil {
-		return err
-	}
-	_, err = w.Write(quote.Marshal())
-	if err != nil {
+	// gzip 最高压缩后上传
+	size, err := saveDay(ctx, s, quote, func(zipped []byte) error {
+		_, err := s.svc.PutObjectWithContext(ctx, &s3.PutObjectInput{
+			Bucket:       aws.String(s.config.Bucket),
+			Key:          aws.String(s.savePath(quote.Market, quote.Date)),
+			Body:         bytes.NewReader(zipped),
+			StorageClass: aws.String(s3.ObjectStorageClassReducedRedundancy),
+		})
 		return err
-	}
-	w.Flush()
-	w.Close()
-
-	zipped, err := ioutil.ReadAll(buffer)
+	})
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	// 上传
-	_, err = s.svc.PutObject(&s3.PutObjectInput{
-		Bucket:       aws.String(s.config.Bucket),
-		Key:          aws.String(s.savePath(quote.Market, quote.Date)),
-		Body:         bytes.NewReader(zipped),
-		StorageClass: aws.String(s3.ObjectStorageClassReducedRedundancy),
-	})
+	saveBytes.Observe(float64(size), "amazon")
 
-	return err
+	return size, nil
 }
 
 // savePath 保存到S3的路径
@@ -108,12 +99,23 @@ func (s AmazonS3) savePath(_market market.Market, date time.Time) string {
 	return fmt.Sprintf("%s%s/%s.mdq", s.config.KeyRoot, date.Format("2006/01/02"), strings.ToLower(_market.Name()))
 }
 
-// Load 读取
-func (s AmazonS3) Load(_market market.Market, date time.Time) (market.DailyQuote, error) {
+// Load 读取，按公司保存的报价覆盖当天整体数据中的同一家公司
+func (s AmazonS3) Load(ctx context.Context, _market market.Market, date time.Time) (market.DailyQuote, error) {
+
+	mdq, err := s.loadDay(ctx, _market, date)
+	if err != nil {
+		return mdq, err
+	}
+
+	return mdq, overlayCompanies(ctx, s, &mdq)
+}
+
+// loadDay 读取当天整体数据
+func (s AmazonS3) loadDay(ctx context.Context, _market market.Market, date time.Time) (market.DailyQuote, error) {
 
 	mdq := market.DailyQuote{Market: _market, Date: date}
 
-	output, err := s.svc.GetObject(&s3.GetObjectInput{
+	output, err := s.svc.GetObjectWithContext(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.config.Bucket),
 		Key:    aws.String(s.savePath(_market, date)),
 	})
@@ -137,3 +139,204 @@ func (s AmazonS3) Load(_market market.Market, date time.Time) (market.DailyQuote
 
 	return mdq, nil
 }
+
+// getRange 读取当天整体数据中的一段
+func (s AmazonS3) getRange(ctx context.Context, _market market.Market, date time.Time, offset, length int64) ([]byte, error) {
+
+	output, err := s.svc.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.config.Bucket),
+		Key:    aws.String(s.savePath(_market, date)),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer output.Body.Close()
+
+	return ioutil.ReadAll(output.Body)
+}
+
+// companiesPath 上市公司列表保存到S3的路径
+func (s AmazonS3) companiesPath(_market market.Market, date time.Time) string {
+	return fmt.Sprintf("%s%s/%s.companies", s.config.KeyRoot, date.Format("2006/01/02"), strings.ToLower(_market.Name()))
+}
+
+// SaveCompanies 保存某天的上市公司列表
+func (s AmazonS3) SaveCompanies(ctx context.Context, _market market.Market, date time.Time, companies []market.Company) error {
+
+	zipped, err := gzipBytes(market.CompanyList(companies).Marshal())
+	if err != nil {
+		return err
+	}
+
+	_, err = s.svc.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.config.Bucket),
+		Key:    aws.String(s.companiesPath(_market, date)),
+		Body:   bytes.NewReader(zipped),
+	})
+
+	return err
+}
+
+// LoadCompanies 读取某天的上市公司列表
+func (s AmazonS3) LoadCompanies(ctx context.Context, _market market.Market, date time.Time) ([]market.Company, error) {
+
+	output, err := s.svc.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.config.Bucket),
+		Key:    aws.String(s.companiesPath(_market, date)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer output.Body.Close()
+
+	zipped, err := ioutil.ReadAll(output.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer, err := gunzipBytes(zipped)
+	if err != nil {
+		return nil, err
+	}
+
+	var companies market.CompanyList
+	companies.Unmarshal(buffer)
+
+	return companies, nil
+}
+
+// attachmentPath 附件保存到S3的路径
+func (s AmazonS3) attachmentPath(_market market.Market, date time.Time, name string) string {
+	return fmt.Sprintf("%s%s/%s.%s", s.config.KeyRoot, date.Format("2006/01/02"), strings.ToLower(_market.Name()), name)
+}
+
+// SaveAttachment 保存某天的附件
+func (s AmazonS3) SaveAttachment(ctx context.Context, _market market.Market, date time.Time, name string, data []byte) error {
+
+	_, err := s.svc.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.config.Bucket),
+		Key:    aws.String(s.attachmentPath(_market, date, name)),
+		Body:   bytes.NewReader(data),
+	})
+
+	return err
+}
+
+// LoadAttachment 读取某天的附件
+func (s AmazonS3) LoadAttachment(ctx context.Context, _market market.Market, date time.Time, name string) ([]byte, error) {
+
+	output, err := s.svc.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.config.Bucket),
+		Key:    aws.String(s.attachmentPath(_market, date, name)),
+	})
+	if err != nil {
+		if ae, ok := err.(awserr.Error); ok && ae.Code() == s3.ErrCodeNoSuchKey {
+			return nil, ErrAttachmentNotFound
+		}
+		return nil, err
+	}
+	defer output.Body.Close()
+
+	return ioutil.ReadAll(output.Body)
+}
+
+// companyPrefix 按公司保存的报价在S3的路径前缀
+func (s AmazonS3) companyPrefix(_market market.Market, date time.Time) string {
+	return fmt.Sprintf("%s%s/%s/", s.config.KeyRoot, date.Format("2006/01/02"), strings.ToLower(_market.Name()))
+}
+
+// putCompany 写入一家公司的报价
+func (s AmazonS3) putCompany(ctx context.Context, _market market.Market, date time.Time, code string, zipped []byte) error {
+
+	_, err := s.svc.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.config.Bucket),
+		Key:    aws.String(s.companyPrefix(_market, date) + companyFileName(code)),
+		Body:   bytes.NewReader(zipped),
+	})
+
+	return err
+}
+
+// deleteCompany 删除一家公司的报价
+func (s AmazonS3) deleteCompany(ctx context.Context, _market market.Market, date time.Time, code string) error {
+
+	// S3删除不存在的对象也返回成功
+	_, err := s.svc.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.config.Bucket),
+		Key:    aws.String(s.companyPrefix(_market, date) + companyFileName(code)),
+	})
+
+	return err
+}
+
+// getCompany 读取一家公司的报价
+func (s AmazonS3) getCompany(ctx context.Context, _market market.Market, date time.Time, code string) ([]byte, bool, error) {
+
+	output, err := s.svc.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.config.Bucket),
+		Key:    aws.String(s.companyPrefix(_market, date) + companyFileName(code)),
+	})
+	if err != nil {
+		if ae, ok := err.(awserr.Error); ok && ae.Code() == s3.ErrCodeNoSuchKey {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	defer output.Body.Close()
+
+	zipped, err := ioutil.ReadAll(output.Body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return zipped, true, nil
+}
+
+// companyCodes 当天按公司保存的公司代码
+func (s AmazonS3) companyCodes(ctx context.Context, _market market.Market, date time.Time) ([]string, error) {
+
+	prefix := s.companyPrefix(_market, date)
+
+	var codes []string
+	err := s.svc.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.config.Bucket),
+		Prefix: aws.String(prefix),
+	}, func(output *s3.ListObjectsV2Output, last bool) bool {
+
+		for _, object := range output.Contents {
+			if code, ok := companyCode(strings.TrimPrefix(aws.StringValue(object.Key), prefix)); ok {
+				codes = append(codes, code)
+			}
+		}
+
+		return true
+	})
+
+	return codes, err
+}
+
+// SaveCompany 按公司保存某天的报价，返回写入的字节数
+func (s AmazonS3) SaveCompany(ctx context.Context, _market market.Market, date time.Time, quote market.CompanyDailyQuote) (int, error) {
+	return saveCompany(ctx, s, _market, date, quote)
+}
+
+// LoadCompany 读取一家公司某天的报价
+func (s AmazonS3) LoadCompany(ctx context.Context, _market market.Market, date time.Time, code string) (market.CompanyDailyQuote, error) {
+	return loadCompany(ctx, s, _market, date, code, func() (market.DailyQuote, error) {
+		return s.loadDay(ctx, _market, date)
+	})
+}
+
+// ListCompanies 某天有报价的公司代码
+func (s AmazonS3) ListCompanies(ctx context.Context, _market market.Market, date time.Time) ([]string, error) {
+
+	exists, err := s.Exists(ctx, _market, date)
+	if err != nil {
+		return nil, err
+	}
+
+	return listCompanies(ctx, s, _market, date, exists, func() (market.DailyQuote, error) {
+		return s.loadDay(ctx, _market, date)
+	})
+}
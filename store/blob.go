@@ -0,0 +1,41 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+)
+
+// gzipBytes gzip最高压缩
+func gzipBytes(data []byte) ([]byte, error) {
+
+	buffer := new(bytes.Buffer)
+	w, err := gzip.NewWriterLevel(buffer, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = w.Write(data)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// gunzipBytes 解压gzip
+func gunzipBytes(zipped []byte) ([]byte, error) {
+
+	reader, err := gzip.NewReader(bytes.NewReader(zipped))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return ioutil.ReadAll(reader)
+}
@@ -0,0 +1,394 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nzai/stockrecorder/market"
+)
+
+const (
+	// companyExt 按公司保存的报价的扩展名
+	companyExt = ".cdq"
+	// indexName 当天数据索引的附件名
+	indexName = "index"
+)
+
+var (
+	// ErrCompanyNotFound 当天没有该公司的报价
+	ErrCompanyNotFound = errors.New("当天没有该公司的报价")
+)
+
+// companyObjects 按公司保存的对象，FileSystem、AliyunOSS、AmazonS3在当天整体数据旁按公司保存，覆盖整体数据中的同一家公司
+type companyObjects interface {
+	// 写入一家公司的报价(已压缩)
+	putCompany(ctx context.Context, _market market.Market, date time.Time, code string, zipped []byte) error
+	// 读取一家公司的报价(已压缩)，不存在时found为false
+	getCompany(ctx context.Context, _market market.Market, date time.Time, code string) (zipped []byte, found bool, err error)
+	// 当天按公司保存的公司代码
+	companyCodes(ctx context.Context, _market market.Market, date time.Time) ([]string, error)
+	// 删除一家公司的报价，不存在时不报错
+	deleteCompany(ctx context.Context, _market market.Market, date time.Time, code string) error
+	// 读取当天整体数据中的一段
+	getRange(ctx context.Context, _market market.Market, date time.Time, offset, length int64) ([]byte, error)
+	// 保存某天的附件
+	SaveAttachment(ctx context.Context, _market market.Market, date time.Time, name string, data []byte) error
+	// 读取某天的附件
+	LoadAttachment(ctx context.Context, _market market.Market, date time.Time, name string) ([]byte, error)
+}
+
+// dayIndex 当天数据的索引，保存为附件。整体数据中每家公司是单独的gzip成员(连起来仍是完整的gzip)，按公司读取时只读取该公司的成员
+type dayIndex struct {
+	Members   map[string][2]int64 `json:"members"`   // 代码 -> 该公司的gzip成员在整体数据中的位置、长度，旧数据为空
+	Companies []string            `json:"companies"` // 按公司保存(覆盖整体数据)的公司代码
+}
+
+// hasCompany 是否按公司保存了该公司
+func (idx dayIndex) hasCompany(code string) bool {
+
+	for _, c := range idx.Companies {
+		if strings.EqualFold(c, code) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// loadIndex 读取当天数据的索引，没有时found为false
+func loadIndex(ctx context.Context, o companyObjects, _market market.Market, date time.Time) (dayIndex, bool, error) {
+
+	var idx dayIndex
+
+	buffer, err := o.LoadAttachment(ctx, _market, date, indexName)
+	if err == ErrAttachmentNotFound {
+		return idx, false, nil
+	}
+	if err != nil {
+		return idx, false, err
+	}
+
+	err = json.Unmarshal(buffer, &idx)
+	if err != nil {
+		return idx, false, fmt.Errorf("解析当天数据的索引时发生错误: %v", err)
+	}
+
+	return idx, true, nil
+}
+
+// saveIndex 保存当天数据的索引
+func saveIndex(ctx context.Context, o companyObjects, _market market.Market, date time.Time, idx dayIndex) error {
+
+	buffer, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+
+	return o.SaveAttachment(ctx, _market, date, indexName, buffer)
+}
+
+// zipDay 压缩当天整体数据，头部及每家公司分别为一个gzip成员，同时返回各公司成员的位置
+func zipDay(quote market.DailyQuote) ([]byte, dayIndex, error) {
+
+	data := quote.Marshal()
+	idx := dayIndex{Members: make(map[string][2]int64, len(quote.Quotes))}
+
+	buffer := new(bytes.Buffer)
+	w, err := gzip.NewWriterLevel(buffer, gzip.BestCompression)
+	if err != nil {
+		return nil, idx, err
+	}
+
+	member := func(part []byte) error {
+		w.Reset(buffer)
+		_, err := w.Write(part)
+		if err != nil {
+			return err
+		}
+		return w.Close()
+	}
+
+	// 头部: 日期、公司数及各公司的位置
+	err = member(data[:12+len(quote.Quotes)*4])
+	if err != nil {
+		return nil, idx, err
+	}
+
+	for index, q := range quote.Quotes {
+
+		start := int(binary.BigEndian.Uint32(data[12+index*4 : 16+index*4]))
+		end := len(data)
+		if index+1 < len(quote.Quotes) {
+			end = int(binary.BigEndian.Uint32(data[16+index*4 : 20+index*4]))
+		}
+
+		offset := int64(buffer.Len())
+		err = member(data[start:end])
+		if err != nil {
+			return nil, idx, err
+		}
+
+		idx.Members[strings.ToUpper(q.Code)] = [2]int64{offset, int64(buffer.Len()) - offset}
+	}
+
+	return buffer.Bytes(), idx, nil
+}
+
+// saveDay 整体保存当天数据及索引，put写入压缩后的整体数据，返回写入的字节数
+func saveDay(ctx context.Context, o companyObjects, quote market.DailyQuote, put func(zipped []byte) error) (int, error) {
+
+	zipped, idx, err := zipDay(quote)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	err = put(zipped)
+	if err != nil {
+		return 0, err
+	}
+
+	// 新的索引中没有按公司保存的公司，即使下面删除失败，旧的报价也不会再覆盖刚保存的数据
+	err = saveIndex(ctx, o, quote.Market, quote.Date, idx)
+	if err != nil {
+		return 0, err
+	}
+
+	// 整体数据已包含当天所有公司，删除按公司保存的旧报价
+	return len(zipped), removeCompanies(ctx, o, quote.Market, quote.Date)
+}
+
+// companyFileName 按公司保存的文件名，代码中可能有/等字符，需要转义
+func companyFileName(code string) string {
+	return url.PathEscape(strings.ToUpper(code)) + companyExt
+}
+
+// companyCode 从文件名还原公司代码，不是按公司保存的文件时返回false
+func companyCode(fileName string) (string, bool) {
+
+	if !strings.HasSuffix(fileName, companyExt) {
+		return "", false
+	}
+
+	code, err := url.PathUnescape(strings.TrimSuffix(fileName, companyExt))
+	if err != nil {
+		return "", false
+	}
+
+	return code, true
+}
+
+// saveCompany 按公司保存报价，并记录到当天的索引中，返回压缩后的字节数
+func saveCompany(ctx context.Context, o companyObjects, _market market.Market, date time.Time, quote market.CompanyDailyQuote) (int, error) {
+
+	zipped, err := gzipBytes(quote.Marshal())
+	if err != nil {
+		return 0, err
+	}
+
+	err = o.putCompany(ctx, _market, date, quote.Code, zipped)
+	if err != nil {
+		return 0, err
+	}
+
+	idx, _, err := loadIndex(ctx, o, _market, date)
+	if err != nil {
+		return 0, err
+	}
+
+	if idx.hasCompany(quote.Code) {
+		return len(zipped), nil
+	}
+
+	idx.Companies = uniqueCodes(append(idx.Companies, quote.Code))
+
+	return len(zipped), saveIndex(ctx, o, _market, date, idx)
+}
+
+// removeCompanies 删除当天按公司保存的报价，整体保存(Save)之后调用
+func removeCompanies(ctx context.Context, o companyObjects, _market market.Market, date time.Time) error {
+
+	codes, err := o.companyCodes(ctx, _market, date)
+	if err != nil {
+		return err
+	}
+
+	for _, code := range codes {
+		err = o.deleteCompany(ctx, _market, date, code)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unzipCompany 解压一家公司的报价
+func unzipCompany(zipped []byte) (market.CompanyDailyQuote, error) {
+
+	quote := market.CompanyDailyQuote{}
+
+	buffer, err := gunzipBytes(zipped)
+	if err != nil {
+		return quote, err
+	}
+
+	quote.Unmarshal(buffer)
+
+	return quote, nil
+}
+
+// loadCompany 读取一家公司的报价，优先读取按公司保存的报价，其次按索引只读取整体数据中该公司的部分，没有索引的旧数据才读取当天整体数据
+func loadCompany(ctx context.Context, o companyObjects, _market market.Market, date time.Time, code string, loadDay func() (market.DailyQuote, error)) (market.CompanyDailyQuote, error) {
+
+	idx, found, err := loadIndex(ctx, o, _market, date)
+	if err != nil {
+		return market.CompanyDailyQuote{}, err
+	}
+
+	if found && idx.hasCompany(code) {
+		zipped, found, err := o.getCompany(ctx, _market, date, code)
+		if err != nil {
+			return market.CompanyDailyQuote{}, err
+		}
+
+		if found {
+			return unzipCompany(zipped)
+		}
+	}
+
+	if found && idx.Members != nil {
+		member, found := idx.Members[strings.ToUpper(code)]
+		if !found {
+			return market.CompanyDailyQuote{}, ErrCompanyNotFound
+		}
+
+		zipped, err := o.getRange(ctx, _market, date, member[0], member[1])
+		if err != nil {
+			return market.CompanyDailyQuote{}, err
+		}
+
+		// 索引与整体数据不一致时(如整体数据被其他程序改写)读取整体数据
+		quote, err := unzipCompany(zipped)
+		if err == nil && strings.EqualFold(quote.Code, code) {
+			return quote, nil
+		}
+	}
+
+	dailyQuote, err := loadDay()
+	if err != nil {
+		return market.CompanyDailyQuote{}, err
+	}
+
+	for _, quote := range dailyQuote.Quotes {
+		if strings.EqualFold(quote.Code, code) {
+			return quote, nil
+		}
+	}
+
+	return market.CompanyDailyQuote{}, ErrCompanyNotFound
+}
+
+// listCompanies 当天有报价的公司代码，包括整体数据中的和按公司保存的，有索引时不读取整体数据
+func listCompanies(ctx context.Context, o companyObjects, _market market.Market, date time.Time, exists bool, loadDay func() (market.DailyQuote, error)) ([]string, error) {
+
+	idx, found, err := loadIndex(ctx, o, _market, date)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := idx.Companies
+	for code := range idx.Members {
+		codes = append(codes, code)
+	}
+
+	if exists && (!found || idx.Members == nil) {
+		dailyQuote, err := loadDay()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, quote := range dailyQuote.Quotes {
+			codes = append(codes, quote.Code)
+		}
+	}
+
+	return uniqueCodes(codes), nil
+}
+
+// overlayCompanies 用按公司保存的报价覆盖当天整体数据中的同一家公司，按公司保存的公司记录在索引中，不需要列出对象
+func overlayCompanies(ctx context.Context, o companyObjects, mdq *market.DailyQuote) error {
+
+	idx, found, err := loadIndex(ctx, o, mdq.Market, mdq.Date)
+	if err != nil || !found || len(idx.Companies) == 0 {
+		return err
+	}
+
+	dict := make(map[string]int, len(mdq.Quotes))
+	for index, quote := range mdq.Quotes {
+		dict[strings.ToUpper(quote.Code)] = index
+	}
+
+	for _, code := range idx.Companies {
+
+		zipped, found, err := o.getCompany(ctx, mdq.Market, mdq.Date, code)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			continue
+		}
+
+		quote, err := unzipCompany(zipped)
+		if err != nil {
+			return err
+		}
+
+		if index, found := dict[strings.ToUpper(quote.Code)]; found {
+			mdq.Quotes[index] = quote
+		} else {
+			mdq.Quotes = append(mdq.Quotes, quote)
+		}
+	}
+
+	// 按Code排序
+	sort.Slice(mdq.Quotes, func(i, j int) bool {
+		return mdq.Quotes[i].Code < mdq.Quotes[j].Code
+	})
+
+	return nil
+}
+
+// uniqueCodes 去重并排序
+func uniqueCodes(codes []string) []string {
+
+	dict := make(map[string]bool, len(codes))
+	var unique []string
+	for _, code := range codes {
+
+		code = strings.ToUpper(code)
+		if dict[code] {
+			continue
+		}
+
+		dict[code] = true
+		unique = append(unique, code)
+	}
+
+	sort.Strings(unique)
+
+	return unique
+}
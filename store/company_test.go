@@ -0,0 +1,170 @@
+package store
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nzai/stockrecorder/market"
+)
+
+// testQuote 只有一笔盘中报价的公司报价
+func testQuote(code string, timestamp, price uint32) market.CompanyDailyQuote {
+	return market.CompanyDailyQuote{
+		Company: market.Company{Code: code, Name: code},
+		Regular: market.QuoteSeries{
+			Count:     1,
+			Timestamp: []uint32{timestamp},
+			Open:      []uint32{price},
+			Close:     []uint32{price},
+			Max:       []uint32{price},
+			Min:       []uint32{price},
+			Volume:    []uint32{100},
+		},
+	}
+}
+
+func TestSaveReplacesCompanies(t *testing.T) {
+
+	ctx := context.Background()
+	s := NewFileSystem(FileSystemConfig{StoreRoot: t.TempDir()})
+	date := time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)
+	_market := market.America{}
+
+	// 旧的按公司保存的报价
+	_, err := s.SaveCompany(ctx, _market, date, testQuote("AAPL", 1514903400, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 整体保存当天的新数据
+	saved := market.DailyQuote{
+		Market: _market,
+		Date:   date,
+		Quotes: []market.CompanyDailyQuote{testQuote("AAPL", 1514903460, 200), testQuote("MSFT", 1514903460, 300)},
+	}
+	_, err = s.Save(ctx, saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := s.Load(ctx, _market, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = saved.Equal(loaded)
+	if err != nil {
+		t.Fatalf("读取的数据与保存的不一致: %v", err)
+	}
+
+	codes, err := s.companyCodes(ctx, _market, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != 0 {
+		t.Fatalf("整体保存后仍有按公司保存的报价: %v", codes)
+	}
+
+	// 整体保存后再按公司保存的报价覆盖整体数据
+	updated := testQuote("MSFT", 1514903520, 400)
+	_, err = s.SaveCompany(ctx, _market, date, updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quote, err := s.LoadCompany(ctx, _market, date, "msft")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = updated.Equal(quote)
+	if err != nil {
+		t.Fatalf("按公司读取的数据与保存的不一致: %v", err)
+	}
+}
+
+// countingFileSystem 记录读取整体数据中的哪些部分
+type countingFileSystem struct {
+	*FileSystem
+	ranges [][2]int64
+}
+
+func (s *countingFileSystem) getRange(ctx context.Context, _market market.Market, date time.Time, offset, length int64) ([]byte, error) {
+	s.ranges = append(s.ranges, [2]int64{offset, length})
+	return s.FileSystem.getRange(ctx, _market, date, offset, length)
+}
+
+func TestLoadCompanyReadsOnlyItsMember(t *testing.T) {
+
+	ctx := context.Background()
+	s := &countingFileSystem{FileSystem: NewFileSystem(FileSystemConfig{StoreRoot: t.TempDir()})}
+	date := time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)
+	_market := market.America{}
+
+	saved := market.DailyQuote{
+		Market: _market,
+		Date:   date,
+		Quotes: []market.CompanyDailyQuote{testQuote("AAPL", 1514903460, 200), testQuote("MSFT", 1514903460, 300), testQuote("SPY", 1514903460, 400)},
+	}
+	size, err := s.Save(ctx, saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 整体数据仍是完整的gzip
+	loaded, err := s.Load(ctx, _market, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = saved.Equal(loaded)
+	if err != nil {
+		t.Fatalf("读取的数据与保存的不一致: %v", err)
+	}
+
+	info, err := os.Stat(s.storePath(_market, date))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 返回的是写入文件的压缩后大小
+	if int64(size) != info.Size() {
+		t.Errorf("写入了%d字节，文件大小为%d字节", size, info.Size())
+	}
+
+	loadDay := func() (market.DailyQuote, error) {
+		t.Error("按公司读取时读取了整体数据")
+		return s.loadDay(ctx, _market, date)
+	}
+
+	quote, err := loadCompany(ctx, s, _market, date, "msft", loadDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = saved.Quotes[1].Equal(quote)
+	if err != nil {
+		t.Fatalf("按公司读取的数据与保存的不一致: %v", err)
+	}
+
+	if len(s.ranges) != 1 || s.ranges[0][1] >= info.Size() {
+		t.Errorf("应只读取MSFT的部分(整体数据%d字节)，实际读取了%v", info.Size(), s.ranges)
+	}
+
+	_, err = loadCompany(ctx, s, _market, date, "QQQ", loadDay)
+	if err != ErrCompanyNotFound {
+		t.Errorf("没有该公司时应返回ErrCompanyNotFound: %v", err)
+	}
+
+	codes, err := listCompanies(ctx, s, _market, date, true, loadDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(codes, ",") != "AAPL,MSFT,SPY" {
+		t.Errorf("公司代码不正确: %v", codes)
+	}
+}
@@ -1,6 +1,9 @@
 package store
 
 import (
+	"context"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
@@ -36,20 +39,58 @@ func (s FileSystem) storePath(_market market.Market, date time.Time) string {
 }
 
 // Exists 判断是否存在
-func (s FileSystem) Exists(_market market.Market, date time.Time) (bool, error) {
+func (s FileSystem) Exists(ctx context.Context, _market market.Market, date time.Time) (bool, error) {
+
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
 	return io.IsExists(s.storePath(_market, date)), nil
 }
 
-// Save 保存
-func (s FileSystem) Save(quote market.DailyQuote) error {
-	return io.WriteGzipBytes(s.storePath(quote.Market, quote.Date), quote.Marshal())
+// Save 保存，返回写入的字节数
+func (s FileSystem) Save(ctx context.Context, quote market.DailyQuote) (int, error) {
+
+	path := s.storePath(quote.Market, quote.Date)
+	size, err := saveDay(ctx, s, quote, func(zipped []byte) error {
+
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			return err
+		}
+
+		return ioutil.WriteFile(path, zipped, 0660)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// 记录写入的字节数
+	saveBytes.Observe(float64(size), "filesystem")
+
+	return size, nil
+}
+
+// Load 读取，按公司保存的报价覆盖当天整体数据中的同一家公司
+func (s FileSystem) Load(ctx context.Context, _market market.Market, date time.Time) (market.DailyQuote, error) {
+
+	mdq, err := s.loadDay(ctx, _market, date)
+	if err != nil {
+		return mdq, err
+	}
+
+	return mdq, overlayCompanies(ctx, s, &mdq)
 }
 
-// Load 读取
-func (s FileSystem) Load(_market market.Market, date time.Time) (market.DailyQuote, error) {
+// loadDay 读取当天整体数据
+func (s FileSystem) loadDay(ctx context.Context, _market market.Market, date time.Time) (market.DailyQuote, error) {
 
 	mdq := market.DailyQuote{Market: _market, Date: date}
 
+	if err := ctx.Err(); err != nil {
+		return mdq, err
+	}
+
 	buffer, err := io.ReadAllGzipBytes(s.storePath(_market, date))
 	if err != nil {
 		return mdq, err
@@ -59,3 +100,213 @@ func (s FileSystem) Load(_market market.Market, date time.Time) (market.DailyQuo
 
 	return mdq, nil
 }
+
+// getRange 读取当天整体数据中的一段
+func (s FileSystem) getRange(ctx context.Context, _market market.Market, date time.Time, offset, length int64) ([]byte, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(s.storePath(_market, date))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	buffer := make([]byte, length)
+	_, err = file.ReadAt(buffer, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer, nil
+}
+
+// companiesPath 上市公司列表的存储路径
+func (s FileSystem) companiesPath(_market market.Market, date time.Time) string {
+	return filepath.Join(
+		s.config.StoreRoot,
+		date.Format("2006"),
+		date.Format("01"),
+		date.Format("02"),
+		strings.ToLower(_market.Name())+".companies",
+	)
+}
+
+// SaveCompanies 保存某天的上市公司列表
+func (s FileSystem) SaveCompanies(ctx context.Context, _market market.Market, date time.Time, companies []market.Company) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return io.WriteGzipBytes(s.companiesPath(_market, date), market.CompanyList(companies).Marshal())
+}
+
+// LoadCompanies 读取某天的上市公司列表
+func (s FileSystem) LoadCompanies(ctx context.Context, _market market.Market, date time.Time) ([]market.Company, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	buffer, err := io.ReadAllGzipBytes(s.companiesPath(_market, date))
+	if err != nil {
+		return nil, err
+	}
+
+	var companies market.CompanyList
+	companies.Unmarshal(buffer)
+
+	return companies, nil
+}
+
+// attachmentPath 附件的存储路径
+func (s FileSystem) attachmentPath(_market market.Market, date time.Time, name string) string {
+	return filepath.Join(
+		s.config.StoreRoot,
+		date.Format("2006"),
+		date.Format("01"),
+		date.Format("02"),
+		strings.ToLower(_market.Name())+"."+name,
+	)
+}
+
+// SaveAttachment 保存某天的附件
+func (s FileSystem) SaveAttachment(ctx context.Context, _market market.Market, date time.Time, name string, data []byte) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	path := s.attachmentPath(_market, date, name)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// LoadAttachment 读取某天的附件
+func (s FileSystem) LoadAttachment(ctx context.Context, _market market.Market, date time.Time, name string) ([]byte, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(s.attachmentPath(_market, date, name))
+	if os.IsNotExist(err) {
+		return nil, ErrAttachmentNotFound
+	}
+
+	return data, err
+}
+
+// companyDir 按公司保存的报价的目录
+func (s FileSystem) companyDir(_market market.Market, date time.Time) string {
+	return filepath.Join(
+		s.config.StoreRoot,
+		date.Format("2006"),
+		date.Format("01"),
+		date.Format("02"),
+		strings.ToLower(_market.Name()),
+	)
+}
+
+// putCompany 写入一家公司的报价
+func (s FileSystem) putCompany(ctx context.Context, _market market.Market, date time.Time, code string, zipped []byte) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	dir := s.companyDir(_market, date)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, companyFileName(code)), zipped, 0644)
+}
+
+// getCompany 读取一家公司的报价
+func (s FileSystem) getCompany(ctx context.Context, _market market.Market, date time.Time, code string) ([]byte, bool, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	zipped, err := ioutil.ReadFile(filepath.Join(s.companyDir(_market, date), companyFileName(code)))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+
+	return zipped, err == nil, err
+}
+
+// deleteCompany 删除一家公司的报价
+func (s FileSystem) deleteCompany(ctx context.Context, _market market.Market, date time.Time, code string) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err := os.Remove(filepath.Join(s.companyDir(_market, date), companyFileName(code)))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// companyCodes 当天按公司保存的公司代码
+func (s FileSystem) companyCodes(ctx context.Context, _market market.Market, date time.Time) ([]string, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	infos, err := ioutil.ReadDir(s.companyDir(_market, date))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	for _, info := range infos {
+		if code, ok := companyCode(info.Name()); ok && !info.IsDir() {
+			codes = append(codes, code)
+		}
+	}
+
+	return codes, nil
+}
+
+// SaveCompany 按公司保存某天的报价，返回写入的字节数
+func (s FileSystem) SaveCompany(ctx context.Context, _market market.Market, date time.Time, quote market.CompanyDailyQuote) (int, error) {
+	return saveCompany(ctx, s, _market, date, quote)
+}
+
+// LoadCompany 读取一家公司某天的报价
+func (s FileSystem) LoadCompany(ctx context.Context, _market market.Market, date time.Time, code string) (market.CompanyDailyQuote, error) {
+	return loadCompany(ctx, s, _market, date, code, func() (market.DailyQuote, error) {
+		return s.loadDay(ctx, _market, date)
+	})
+}
+
+// ListCompanies 某天有报价的公司代码
+func (s FileSystem) ListCompanies(ctx context.Context, _market market.Market, date time.Time) ([]string, error) {
+
+	exists, err := s.Exists(ctx, _market, date)
+	if err != nil {
+		return nil, err
+	}
+
+	return listCompanies(ctx, s, _market, date, exists, func() (market.DailyQuote, error) {
+		return s.loadDay(ctx, _market, date)
+	})
+}
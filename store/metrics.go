@@ -0,0 +1,10 @@
+package store
+
+import (
+	"github.com/nzai/stockrecorder/metrics"
+)
+
+var (
+	// saveBytes 每次保存写入的字节数
+	saveBytes = metrics.NewHistogram("stockrecorder_store_save_bytes", "每次保存交易日数据写入的字节数", metrics.ByteBuckets, "store")
+)
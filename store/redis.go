@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -42,102 +43,161 @@ func NewRedis(config RedisConfig) *Redis {
 }
 
 // Exists 判断是否存在
-func (s Redis) Exists(_market market.Market, date time.Time) (bool, error) {
+func (s Redis) Exists(ctx context.Context, _market market.Market, date time.Time) (bool, error) {
+
+	client := s.client.WithContext(ctx)
 
 	// key:america:20160101:offset value:18000
 	offsetKey := fmt.Sprintf("%s:%s:offset", strings.ToLower(_market.Name()), date.Format("20060102"))
 
-	return s.client.Exists(offsetKey).Result()
+	return client.Exists(offsetKey).Result()
 }
 
-// Save 保存
-func (s Redis) Save(quote market.DailyQuote) error {
+// Save 保存，返回写入的字节数
+func (s Redis) Save(ctx context.Context, quote market.DailyQuote) (int, error) {
+
+	client := s.client.WithContext(ctx)
+
+	// 整体保存时先删除当天原有的全部公司，避免残留旧的时间点、公司信息及不再存在的公司
+	// key:america:20160101:company value:[a aa aapl fb ibm ...]
+	companyKey := fmt.Sprintf("%s:%s:company", strings.ToLower(quote.Market.Name()), quote.Date.Format("20060102"))
+	codes, err := client.SMembers(companyKey).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, code := range codes {
+		err = s.deleteCompany(client, quote.Market, quote.Date, code, "name")
+		if err != nil {
+			return 0, err
+		}
+	}
 
+	err = client.Del(companyKey).Err()
+	if err != nil {
+		return 0, err
+	}
+
+	var written int
 	for _, cdq := range quote.Quotes {
 
-		err := s.saveCompanyDailyQuote(quote.Market, quote.Date, cdq)
+		// 逐个公司保存，取消时尽早返回
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		size, err := s.saveCompanyDailyQuote(client, quote.Market, quote.Date, cdq)
 		if err != nil {
-			return err
+			return 0, err
 		}
+		written += size
 	}
 
 	// key:america:20160101:offset value:18000
 	offsetKey := fmt.Sprintf("%s:%s:offset", strings.ToLower(quote.Market.Name()), quote.Date.Format("20060102"))
-	err := s.client.Set(offsetKey, strconv.Itoa(quote.UTCOffset), 0).Err()
+	err = client.Set(offsetKey, strconv.Itoa(quote.UTCOffset), 0).Err()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	saveBytes.Observe(float64(written), "redis")
+
+	return written, nil
 }
 
-// saveCompanyDailyQuote 保存公司报价
-func (s Redis) saveCompanyDailyQuote(_market market.Market, date time.Time, cdq market.CompanyDailyQuote) error {
+// saveCompanyDailyQuote 保存公司报价，返回写入的字节数
+func (s Redis) saveCompanyDailyQuote(client *redis.Client, _market market.Market, date time.Time, cdq market.CompanyDailyQuote) (int, error) {
 
 	// key:america:20160101:aapl:name value:Apple Inc.
 	nameKey := fmt.Sprintf("%s:%s:%s:name", strings.ToLower(_market.Name()), date.Format("20060102"), strings.ToLower(cdq.Code))
-	err := s.client.Set(nameKey, cdq.Name, 0).Err()
+	err := client.Set(nameKey, cdq.Name, 0).Err()
 	if err != nil {
-		return err
+		return 0, err
 	}
+	written := len(cdq.Name)
 
-	// key:america:20160101:company value:[a aa aapl fb ibm ...]
-	companyKey := fmt.Sprintf("%s:%s:company", strings.ToLower(_market.Name()), date.Format("20060102"))
-	err = s.client.SAdd(companyKey, strings.ToLower(cdq.Code)).Err()
-	if err != nil {
-		return err
+	// key:america:20160101:aapl:meta value:序列化的公司信息(交易所、证券类型等)，只有名称、代码时不保存
+	metaKey := fmt.Sprintf("%s:%s:%s:meta", strings.ToLower(_market.Name()), date.Format("20060102"), strings.ToLower(cdq.Code))
+	if cdq.Company != (market.Company{Name: cdq.Name, Code: cdq.Code}) {
+		meta := cdq.Company.Marshal()
+		err = client.Set(metaKey, meta, 0).Err()
+		if err != nil {
+			return 0, err
+		}
+		written += len(meta)
 	}
 
-	err = s.saveQuoteSerie(_market, date, cdq.Code, "pre", cdq.Pre)
-	if err != nil {
-		return err
+	// key:america:20160101:aapl:segments value:序列化的交易时段，没有时段时不保存
+	if len(cdq.Segments) > 0 {
+		segmentsKey := fmt.Sprintf("%s:%s:%s:segments", strings.ToLower(_market.Name()), date.Format("20060102"), strings.ToLower(cdq.Code))
+		segments := cdq.Segments.Marshal()
+		err = client.Set(segmentsKey, segments, 0).Err()
+		if err != nil {
+			return 0, err
+		}
+		written += len(segments)
 	}
 
-	err = s.saveQuoteSerie(_market, date, cdq.Code, "regular", cdq.Regular)
+	// key:america:20160101:company value:[a aa aapl fb ibm ...]
+	companyKey := fmt.Sprintf("%s:%s:company", strings.ToLower(_market.Name()), date.Format("20060102"))
+	err = client.SAdd(companyKey, strings.ToLower(cdq.Code)).Err()
 	if err != nil {
-		return err
+		return 0, err
 	}
+	written += len(cdq.Code)
 
-	err = s.saveQuoteSerie(_market, date, cdq.Code, "post", cdq.Post)
-	if err != nil {
-		return err
+	for _, serie := range []struct {
+		typeName string
+		series   market.QuoteSeries
+	}{{"pre", cdq.Pre}, {"regular", cdq.Regular}, {"post", cdq.Post}} {
+
+		size, err := s.saveQuoteSerie(client, _market, date, cdq.Code, serie.typeName, serie.series)
+		if err != nil {
+			return 0, err
+		}
+		written += size
 	}
 
-	return nil
+	return written, nil
 }
 
-// saveQuoteSerie 保存报价序列
-func (s Redis) saveQuoteSerie(_market market.Market, date time.Time, code, typeName string, series market.QuoteSeries) error {
+// saveQuoteSerie 保存报价序列，返回写入的字节数
+func (s Redis) saveQuoteSerie(client *redis.Client, _market market.Market, date time.Time, code, typeName string, series market.QuoteSeries) (int, error) {
 
 	if series.Count == 0 {
-		return nil
+		return 0, nil
 	}
 
 	// key:america:20160101:aapl:pre field:timestamp value:open|close|max|min|volume
 	key := fmt.Sprintf("%s:%s:%s:%s", strings.ToLower(_market.Name()), date.Format("20060102"), strings.ToLower(code), typeName)
 
+	var written int
 	values := make(map[string]string, series.Count)
 	for index := 0; index < int(series.Count); index++ {
 
-		values[strconv.Itoa(int(series.Timestamp[index]))] = fmt.Sprintf("%d|%d|%d|%d|%d",
+		field := strconv.Itoa(int(series.Timestamp[index]))
+		values[field] = fmt.Sprintf("%d|%d|%d|%d|%d",
 			series.Open[index],
 			series.Close[index],
 			series.Max[index],
 			series.Min[index],
 			series.Volume[index],
 		)
+		written += len(field) + len(values[field])
 	}
 
-	return s.client.HMSet(key, values).Err()
+	return written, client.HMSet(key, values).Err()
 }
 
 // Load 读取
-func (s Redis) Load(_market market.Market, date time.Time) (market.DailyQuote, error) {
+func (s Redis) Load(ctx context.Context, _market market.Market, date time.Time) (market.DailyQuote, error) {
 	mdq := market.DailyQuote{Market: _market, Date: date}
 
+	client := s.client.WithContext(ctx)
+
 	// key:america:20160101:offset value:18000
 	offsetKey := fmt.Sprintf("%s:%s:offset", strings.ToLower(_market.Name()), date.Format("20060102"))
-	offsetString, err := s.client.Get(offsetKey).Result()
+	offsetString, err := client.Get(offsetKey).Result()
 	if err != nil {
 		return mdq, err
 	}
@@ -148,7 +208,7 @@ func (s Redis) Load(_market market.Market, date time.Time) (market.DailyQuote, e
 	}
 
 	companyKey := fmt.Sprintf("%s:%s:company", strings.ToLower(_market.Name()), date.Format("20060102"))
-	companyCodes, err := s.client.SMembers(companyKey).Result()
+	companyCodes, err := client.SMembers(companyKey).Result()
 	if err != nil {
 		return mdq, err
 	}
@@ -157,7 +217,11 @@ func (s Redis) Load(_market market.Market, date time.Time) (market.DailyQuote, e
 	sort.Strings(companyCodes)
 	for _, code := range companyCodes {
 
-		cdq, err := s.loadCompanyDailyQuote(_market, date, code)
+		if err := ctx.Err(); err != nil {
+			return mdq, err
+		}
+
+		cdq, err := s.loadCompanyDailyQuote(client, _market, date, code)
 		if err != nil {
 			return mdq, nil
 		}
@@ -169,29 +233,60 @@ func (s Redis) Load(_market market.Market, date time.Time) (market.DailyQuote, e
 }
 
 // loadCompanyDailyQuote 读取公司报价
-func (s Redis) loadCompanyDailyQuote(_market market.Market, date time.Time, code string) (market.CompanyDailyQuote, error) {
+func (s Redis) loadCompanyDailyQuote(client *redis.Client, _market market.Market, date time.Time, code string) (market.CompanyDailyQuote, error) {
 
 	cdq := market.CompanyDailyQuote{Company: market.Company{Code: strings.ToUpper(code)}}
 
 	// key:america:20160101:aapl:name value:Apple Inc.
 	nameKey := fmt.Sprintf("%s:%s:%s:name", strings.ToLower(_market.Name()), date.Format("20060102"), strings.ToLower(code))
-	name, err := s.client.Get(nameKey).Result()
+	name, err := client.Get(nameKey).Result()
 	if err != nil {
 		return cdq, err
 	}
 	cdq.Name = name
 
+	// key:america:20160101:aapl:meta value:序列化的公司信息
+	metaKey := fmt.Sprintf("%s:%s:%s:meta", strings.ToLower(_market.Name()), date.Format("20060102"), strings.ToLower(code))
+	meta, err := client.Get(metaKey).Bytes()
+	if err != nil && err != redis.Nil {
+		return cdq, err
+	}
+	if err == nil {
+		cdq.Company.Unmarshal(meta)
+	}
+
+	// key:america:20160101:aapl:segments value:序列化的交易时段
+	segmentsKey := fmt.Sprintf("%s:%s:%s:segments", strings.ToLower(_market.Name()), date.Format("20060102"), strings.ToLower(code))
+	segments, err := client.Get(segmentsKey).Bytes()
+	if err != nil && err != redis.Nil {
+		return cdq, err
+	}
+	if err == nil {
+		cdq.Segments.Unmarshal(segments)
+	}
+
+	for _, serie := range []struct {
+		typeName string
+		series   *market.QuoteSeries
+	}{{"pre", &cdq.Pre}, {"regular", &cdq.Regular}, {"post", &cdq.Post}} {
+
+		*serie.series, err = s.loadQuoteSerie(client, _market, date, code, serie.typeName)
+		if err != nil {
+			return cdq, err
+		}
+	}
+
 	return cdq, nil
 }
 
 // loadQuoteSerie 读取报价序列
-func (s Redis) loadQuoteSerie(_market market.Market, date time.Time, code, typeName string) (market.QuoteSeries, error) {
+func (s Redis) loadQuoteSerie(client *redis.Client, _market market.Market, date time.Time, code, typeName string) (market.QuoteSeries, error) {
 
 	qs := market.QuoteSeries{}
 
 	// key:america:20160101:aapl:pre field:timestamp value:open|close|max|min|volume
 	key := fmt.Sprintf("%s:%s:%s:%s", strings.ToLower(_market.Name()), date.Format("20060102"), strings.ToLower(code), typeName)
-	exists, err := s.client.Exists(key).Result()
+	exists, err := client.Exists(key).Result()
 	if err != nil {
 		return qs, err
 	}
@@ -200,7 +295,7 @@ func (s Redis) loadQuoteSerie(_market market.Market, date time.Time, code, typeN
 		return qs, nil
 	}
 
-	values, err := s.client.HGetAll(key).Result()
+	values, err := client.HGetAll(key).Result()
 	if err != nil {
 		return qs, nil
 	}
@@ -214,9 +309,10 @@ func (s Redis) loadQuoteSerie(_market market.Market, date time.Time, code, typeN
 	qs.Min = make([]uint32, count)
 	qs.Volume = make([]uint32, count)
 
-	timestamps := make([]string, len(values))
-	for index, timestamp := range timestamps {
-		timestamps[index] = timestamp
+	// 按时间排序，时间戳位数相同，可以按字符串排序
+	timestamps := make([]string, 0, len(values))
+	for timestamp := range values {
+		timestamps = append(timestamps, timestamp)
 	}
 	sort.Strings(timestamps)
 
@@ -266,3 +362,119 @@ func (s Redis) loadQuoteSerie(_market market.Market, date time.Time, code, typeN
 
 	return qs, nil
 }
+
+// SaveCompanies 保存某天的上市公司列表
+func (s Redis) SaveCompanies(ctx context.Context, _market market.Market, date time.Time, companies []market.Company) error {
+
+	// key:america:20160101:companies value:序列化后的上市公司列表
+	key := fmt.Sprintf("%s:%s:companies", strings.ToLower(_market.Name()), date.Format("20060102"))
+
+	return s.client.WithContext(ctx).Set(key, market.CompanyList(companies).Marshal(), 0).Err()
+}
+
+// LoadCompanies 读取某天的上市公司列表
+func (s Redis) LoadCompanies(ctx context.Context, _market market.Market, date time.Time) ([]market.Company, error) {
+
+	// key:america:20160101:companies value:序列化后的上市公司列表
+	key := fmt.Sprintf("%s:%s:companies", strings.ToLower(_market.Name()), date.Format("20060102"))
+
+	buffer, err := s.client.WithContext(ctx).Get(key).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var companies market.CompanyList
+	companies.Unmarshal(buffer)
+
+	return companies, nil
+}
+
+// SaveAttachment 保存某天的附件
+func (s Redis) SaveAttachment(ctx context.Context, _market market.Market, date time.Time, name string, data []byte) error {
+
+	// key:america:20160101:attachment:quality.json value:附件内容
+	key := fmt.Sprintf("%s:%s:attachment:%s", strings.ToLower(_market.Name()), date.Format("20060102"), name)
+
+	return s.client.WithContext(ctx).Set(key, data, 0).Err()
+}
+
+// LoadAttachment 读取某天的附件
+func (s Redis) LoadAttachment(ctx context.Context, _market market.Market, date time.Time, name string) ([]byte, error) {
+
+	// key:america:20160101:attachment:quality.json value:附件内容
+	key := fmt.Sprintf("%s:%s:attachment:%s", strings.ToLower(_market.Name()), date.Format("20060102"), name)
+
+	data, err := s.client.WithContext(ctx).Get(key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrAttachmentNotFound
+	}
+
+	return data, err
+}
+
+// SaveCompany 按公司保存某天的报价，返回写入的字节数
+func (s Redis) SaveCompany(ctx context.Context, _market market.Market, date time.Time, quote market.CompanyDailyQuote) (int, error) {
+
+	client := s.client.WithContext(ctx)
+
+	// 先删除原有的报价序列及公司信息，避免残留旧的时间点
+	err := s.deleteCompany(client, _market, date, quote.Code)
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := s.saveCompanyDailyQuote(client, _market, date, quote)
+	if err != nil {
+		return 0, err
+	}
+
+	saveBytes.Observe(float64(size), "redis")
+
+	return size, nil
+}
+
+// deleteCompany 删除一家公司的报价序列、公司信息及交易时段，extra为需要一起删除的其他类型(如name)
+func (s Redis) deleteCompany(client *redis.Client, _market market.Market, date time.Time, code string, extra ...string) error {
+
+	for _, typeName := range append([]string{"pre", "regular", "post", "meta", "segments"}, extra...) {
+		key := fmt.Sprintf("%s:%s:%s:%s", strings.ToLower(_market.Name()), date.Format("20060102"), strings.ToLower(code), typeName)
+		err := client.Del(key).Err()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LoadCompany 读取一家公司某天的报价
+func (s Redis) LoadCompany(ctx context.Context, _market market.Market, date time.Time, code string) (market.CompanyDailyQuote, error) {
+
+	client := s.client.WithContext(ctx)
+
+	// key:america:20160101:company value:[a aa aapl fb ibm ...]
+	companyKey := fmt.Sprintf("%s:%s:company", strings.ToLower(_market.Name()), date.Format("20060102"))
+	found, err := client.SIsMember(companyKey, strings.ToLower(code)).Result()
+	if err != nil {
+		return market.CompanyDailyQuote{}, err
+	}
+
+	if !found {
+		return market.CompanyDailyQuote{}, ErrCompanyNotFound
+	}
+
+	return s.loadCompanyDailyQuote(client, _market, date, strings.ToLower(code))
+}
+
+// ListCompanies 某天有报价的公司代码
+func (s Redis) ListCompanies(ctx context.Context, _market market.Market, date time.Time) ([]string, error) {
+
+	// key:america:20160101:company value:[a aa aapl fb ibm ...]
+	companyKey := fmt.Sprintf("%s:%s:company", strings.ToLower(_market.Name()), date.Format("20060102"))
+	codes, err := s.client.WithContext(ctx).SMembers(companyKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return uniqueCodes(codes), nil
+}
@@ -1,17 +1,38 @@
 package store
 
 import (
+	"context"
+	"errors"
 	"time"
 
 	"github.com/nzai/stockrecorder/market"
 )
 
+var (
+	// ErrAttachmentNotFound 当天没有该附件
+	ErrAttachmentNotFound = errors.New("当天没有该附件")
+)
+
 // Store 存储
 type Store interface {
 	// 判断是否记录过
-	Exists(_market market.Market, date time.Time) (bool, error)
-	// 保存
-	Save(quote market.DailyQuote) error
+	Exists(ctx context.Context, _market market.Market, date time.Time) (bool, error)
+	// 保存，返回写入存储的字节数
+	Save(ctx context.Context, quote market.DailyQuote) (int, error)
 	// 读取
-	Load(_market market.Market, date time.Time) (market.DailyQuote, error)
+	Load(ctx context.Context, _market market.Market, date time.Time) (market.DailyQuote, error)
+	// 保存某天的上市公司列表
+	SaveCompanies(ctx context.Context, _market market.Market, date time.Time, companies []market.Company) error
+	// 读取某天的上市公司列表
+	LoadCompanies(ctx context.Context, _market market.Market, date time.Time) ([]market.Company, error)
+	// 保存某天的附件，如数据质量报告
+	SaveAttachment(ctx context.Context, _market market.Market, date time.Time, name string, data []byte) error
+	// 读取某天的附件，没有时返回ErrAttachmentNotFound
+	LoadAttachment(ctx context.Context, _market market.Market, date time.Time, name string) ([]byte, error)
+	// 按公司保存某天的报价，覆盖当天整体数据中的同一家公司，不需要重写当天整体数据，返回写入存储的字节数
+	SaveCompany(ctx context.Context, _market market.Market, date time.Time, quote market.CompanyDailyQuote) (int, error)
+	// 读取一家公司某天的报价
+	LoadCompany(ctx context.Context, _market market.Market, date time.Time, code string) (market.CompanyDailyQuote, error)
+	// 某天有报价的公司代码
+	ListCompanies(ctx context.Context, _market market.Market, date time.Time) ([]string, error)
 }